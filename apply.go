@@ -0,0 +1,114 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ApplyConflictError is returned by Apply in strict mode when the Before
+// value of a change doesn't match the stored row.
+type ApplyConflictError struct {
+	Table string
+
+	// Change is the position of the conflicting change in the applied
+	// changes. Expected is its Before value and Actual is the stored row,
+	// nil if there is none.
+	Change   int
+	Expected interface{}
+	Actual   interface{}
+}
+
+func (e *ApplyConflictError) Error() string {
+	return fmt.Sprintf("change %d of table %q doesn't match the stored row", e.Change, e.Table)
+}
+
+// ApplyOption configures Apply.
+type ApplyOption func(*applyConfig)
+
+type applyConfig struct {
+	strict bool
+}
+
+// ApplyStrict makes Apply verify that the Before value of every change
+// matches the stored row, as compared by reflect.DeepEqual, before applying
+// it. A change without a Before value requires that there is no stored row.
+func ApplyStrict() ApplyOption {
+	return func(c *applyConfig) {
+		c.strict = true
+	}
+}
+
+// Apply replays changes in the transaction, in order: the After value of a
+// change is inserted, or its Before value is deleted if it has no After
+// value. Changes can come from Txn.Changes, Diff, Changes.Invert or a
+// CodecRegistry.
+//
+// A change set already holds the effects of foreign key actions, and may
+// transiently break foreign keys while it is replayed, so Apply doesn't run
+// validators nor enforce foreign keys. Quotas are enforced and rows are
+// evicted from LRU tables as for Insert, and pre-commit hooks run when the
+// transaction is committed, as usual. Without strict mode, deleting a
+// row that doesn't exist is ignored.
+//
+// If an error is returned, the changes applied so far are left in the
+// transaction, which should be aborted.
+func (txn *Txn) Apply(changes Changes, opts ...ApplyOption) error {
+	if !txn.write {
+		return fmt.Errorf("cannot apply changes in read-only transaction")
+	}
+
+	var config applyConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return txn.recordWrite(func(txn *Txn) error {
+		return txn.apply(changes, config)
+	})
+}
+
+// apply implements Apply.
+func (txn *Txn) apply(changes Changes, config applyConfig) error {
+	applying := txn.applying
+	txn.applying = true
+	defer func() { txn.applying = applying }()
+
+	for i, change := range changes {
+		obj := change.After
+		if obj == nil {
+			obj = change.Before
+		}
+		if obj == nil {
+			continue
+		}
+
+		if config.strict {
+			idVal, err := txn.primaryKey(change.Table, obj)
+			if err != nil {
+				return err
+			}
+			existing, _ := txn.indexGet(change.Table, id, idVal)
+			if !reflect.DeepEqual(existing, change.Before) {
+				return &ApplyConflictError{
+					Table:    change.Table,
+					Change:   i,
+					Expected: change.Before,
+					Actual:   existing,
+				}
+			}
+		}
+
+		if change.After != nil {
+			if err := txn.Insert(change.Table, change.After); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := txn.Delete(change.Table, change.Before); err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChanges_Invert(t *testing.T) {
+	a, b, c := testSavepointObj("a", "x"), testSavepointObj("b", "x"), testSavepointObj("b", "y")
+	changes := Changes{
+		{Table: "main", After: a, primaryKey: []byte("a")},
+		{Table: "main", Before: b, After: c, primaryKey: []byte("b")},
+	}
+	expected := Changes{
+		{Table: "main", Before: c, After: b, primaryKey: []byte("b")},
+		{Table: "main", Before: a, primaryKey: []byte("a")},
+	}
+	if inverted := changes.Invert(); !reflect.DeepEqual(inverted, expected) {
+		t.Fatalf("bad: %#v", inverted)
+	}
+	if inverted := expected.Invert(); !reflect.DeepEqual(inverted, changes) {
+		t.Fatalf("bad: %#v", inverted)
+	}
+	if inverted := Changes(nil).Invert(); inverted != nil {
+		t.Fatalf("bad: %#v", inverted)
+	}
+}
+
+func TestTxn_Apply_Undo(t *testing.T) {
+	db := testDB(t)
+	testReplicaWrite(t, db, []*TestObject{testSavepointObj("a", "x"), testSavepointObj("b", "x")})
+	before := testReplicaRows(t, db)
+
+	txn := db.Txn(true)
+	txn.TrackChanges()
+	noErr(t, txn.Insert("main", testSavepointObj("a", "y")))
+	noErr(t, txn.Insert("main", testSavepointObj("c", "x")))
+	noErr(t, txn.Delete("main", testSavepointObj("b", "x")))
+	txn.Commit()
+	changes := txn.Changes()
+
+	txn = db.Txn(true)
+	noErr(t, txn.Apply(changes.Invert(), ApplyStrict()))
+	txn.Commit()
+	if rows := testReplicaRows(t, db); !reflect.DeepEqual(rows, before) {
+		t.Fatalf("bad: %v", rows)
+	}
+
+	// Secondary indexes are restored too
+	raw, err := db.Txn(false).First("main", "foo", "y")
+	noErr(t, err)
+	if raw != nil {
+		t.Fatalf("bad: %#v", raw)
+	}
+
+	// Undoing again conflicts with the current state
+	txn = db.Txn(true)
+	defer txn.Abort()
+	err = txn.Apply(changes.Invert(), ApplyStrict())
+	var conflict *ApplyConflictError
+	if !errors.As(err, &conflict) || conflict.Change != 0 || conflict.Table != "main" {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestTxn_Apply_Replay(t *testing.T) {
+	db := testDB(t)
+	testReplicaWrite(t, db, []*TestObject{testSavepointObj("a", "x"), testSavepointObj("b", "x")})
+	old := db.Snapshot()
+	testReplicaWrite(t, db, []*TestObject{testSavepointObj("c", "x")}, testSavepointObj("a", "x"))
+
+	// A diff between snapshots turns a copy of the old state into the new
+	changes, err := Diff(old, db)
+	noErr(t, err)
+	target := old.Snapshot()
+	txn := target.Txn(true)
+	noErr(t, txn.Apply(changes, ApplyStrict()))
+	txn.Commit()
+	if rows := testReplicaRows(t, target); !reflect.DeepEqual(rows, testReplicaRows(t, db)) {
+		t.Fatalf("bad: %v", rows)
+	}
+
+	// Without strict mode, stale changes are applied anyway
+	txn = target.Txn(true)
+	noErr(t, txn.Apply(changes))
+	txn.Commit()
+	if rows := testReplicaRows(t, target); !reflect.DeepEqual(rows, testReplicaRows(t, db)) {
+		t.Fatalf("bad: %v", rows)
+	}
+
+	if err := db.Txn(false).Apply(changes); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestTxn_Apply_ForeignKeys(t *testing.T) {
+	db, node, alloc := testForeignKeyDB(t, Cascade)
+
+	txn := db.Txn(true)
+	txn.TrackChanges()
+	noErr(t, txn.Delete("nodes", node))
+	txn.Commit()
+	changes := txn.Changes()
+	if len(changes) != 2 {
+		t.Fatalf("bad: %#v", changes)
+	}
+
+	// The alloc is restored before its node, and foreign keys are enforced
+	// again once the changes are applied
+	txn = db.Txn(true)
+	noErr(t, txn.Apply(changes.Invert(), ApplyStrict()))
+	txn.Commit()
+	for table, obj := range map[string]interface{}{"nodes": node, "allocs": alloc} {
+		raw, err := db.Txn(false).First(table, "id")
+		noErr(t, err)
+		if raw != obj {
+			t.Fatalf("bad: %#v", raw)
+		}
+	}
+
+	txn = db.Txn(true)
+	defer txn.Abort()
+	if err := txn.Insert("allocs", &TestAlloc{ID: "alloc2", NodeID: "nope"}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestTxn_Apply_Optimistic(t *testing.T) {
+	db, node, alloc := testForeignKeyDB(t, Cascade)
+	txn := db.Txn(true)
+	txn.TrackChanges()
+	noErr(t, txn.Delete("nodes", node))
+	txn.Commit()
+	changes := txn.Changes()
+
+	// The apply is replayed as a whole at commit, so the alloc can still
+	// be restored before its node
+	txn = db.OptimisticTxn()
+	noErr(t, txn.Apply(changes.Invert()))
+	noErr(t, txn.CommitErr())
+	raw, err := db.Txn(false).First("allocs", "id", "alloc1")
+	noErr(t, err)
+	if raw != alloc {
+		t.Fatalf("bad: %#v", raw)
+	}
+}
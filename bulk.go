@@ -0,0 +1,324 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+// BulkLoadError is returned by BulkLoad when some of the objects couldn't be
+// indexed. All the other objects are loaded.
+type BulkLoadError struct {
+	Table string
+
+	// Errors maps the position of each failed object in the loaded slice to
+	// its error.
+	Errors map[int]error
+}
+
+func (e *BulkLoadError) Error() string {
+	first := -1
+	for i := range e.Errors {
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+	return fmt.Sprintf("failed to load %d objects into table %q, object %d: %v",
+		len(e.Errors), e.Table, first, e.Errors[first])
+}
+
+// BulkLoadOption configures BulkLoad.
+type BulkLoadOption func(*bulkLoadConfig)
+
+type bulkLoadConfig struct {
+	sort bool
+}
+
+// BulkLoadSorted makes BulkLoad sort the keys of each index before inserting
+// them. Inserting in key order copies fewer radix tree nodes, which is
+// usually faster for large loads than the cost of sorting.
+func BulkLoadSorted() BulkLoadOption {
+	return func(c *bulkLoadConfig) {
+		c.sort = true
+	}
+}
+
+// bulkRow is an object to load along with its index values, in the order
+// of the index names returned by indexNames.
+type bulkRow struct {
+	obj   interface{}
+	idVal []byte
+	vals  [][][]byte
+}
+
+// BulkLoad inserts or updates many objects in a table at once. It is faster
+// than calling Insert for each object, since the indexes are written without
+// tracking mutations and without recording changes. Changes are only
+// recorded for the event publisher, see WithEvents.
+//
+// Objects that fail to be indexed are skipped and reported in a
+// *BulkLoadError, the others are loaded. If several objects have the same
+// primary key the last one wins. Nothing is committed if no object is
+// loaded.
+//
+// BulkLoad takes the writer lock of the table and commits on its own. It is
+// meant to restore trusted data, so validators, foreign keys, quotas and
+// pre-commit hooks are not run, and rows aren't evicted from LRU tables.
+// Since mutations aren't tracked, the watches of the queries on the table
+// don't fire: only the watch returned by Txn.TableIndexWatch does.
+func (db *MemDB) BulkLoad(table string, objs []interface{}, opts ...BulkLoadOption) error {
+	var config bulkLoadConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	tableSchema, ok := db.schema.Tables[table]
+	if !ok {
+		return fmt.Errorf("invalid table '%s'", table)
+	}
+
+	// Index the objects first, keeping the last object of each primary key
+	names := indexNames(tableSchema)
+	var errs map[int]error
+	rows := make([]*bulkRow, 0, len(objs))
+	positions := make(map[string]int, len(objs))
+	for i, obj := range objs {
+		row, err := bulkIndex(tableSchema, names, obj)
+		if err != nil {
+			if errs == nil {
+				errs = make(map[int]error)
+			}
+			errs[i] = err
+			continue
+		}
+		if pos, ok := positions[string(row.idVal)]; ok {
+			rows[pos] = row
+			continue
+		}
+		positions[string(row.idVal)] = len(rows)
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		if errs != nil {
+			return &BulkLoadError{Table: table, Errors: errs}
+		}
+		return nil
+	}
+
+	txn, err := db.WriteTxn(table)
+	if err != nil {
+		return err
+	}
+	txn.changes = nil
+
+	// Look up the rows being replaced, and record the changes for the
+	// event publisher
+	raw, _ := txn.rootTxn.Get(indexPath(table, id))
+	oldRows := raw.(*iradix.Tree)
+	replaced := make([]*bulkRow, len(rows))
+	if db.events != nil {
+		txn.changes = make(Changes, 0, len(rows))
+	}
+	for i, row := range rows {
+		before, ok := oldRows.Get(row.idVal)
+		if ok {
+			replaced[i], _ = bulkIndex(tableSchema, names, before)
+		}
+		if db.events != nil {
+			txn.changes = append(txn.changes, Change{
+				Table:      table,
+				Before:     before,
+				After:      row.obj,
+				primaryKey: row.idVal,
+			})
+		}
+	}
+
+	// Look up the versions of the objects being replaced
+	var versions []*rowVersion
+	if tableSchema.Versioned {
+		txn.stamp = new(commitStamp)
+		versions = make([]*rowVersion, len(rows))
+		raw, _ = txn.rootTxn.Get(indexPath(table, versionIndex))
+		oldVersions := raw.(*iradix.Tree)
+		for i, row := range rows {
+			versions[i] = &rowVersion{create: txn.stamp, modify: txn.stamp}
+			if raw, ok := oldVersions.Get(row.idVal); ok {
+				versions[i].create = raw.(*rowVersion).create
+			}
+		}
+	}
+
+	// Write every index of the table without tracking mutations, removing
+	// the keys of the replaced objects before inserting the new ones. The
+	// internal indexes written through writableIndex are set up the same way.
+	txn.modified = make(map[tableIndex]*iradix.Txn)
+	untracked := func(name string) *iradix.Txn {
+		raw, _ := txn.rootTxn.Get(indexPath(table, name))
+		indexTxn := raw.(*iradix.Tree).Txn()
+		txn.modified[tableIndex{table, name}] = indexTxn
+		return indexTxn
+	}
+	if db.tracksUsage(tableSchema) {
+		untracked(usageIndex)
+	}
+	if tableSchema.LRU != nil {
+		untracked(lruIndex)
+		untracked(lruKeyIndex)
+	}
+	for n, name := range names {
+		indexTxn := untracked(name)
+		for _, row := range replaced {
+			if row == nil {
+				continue
+			}
+			for _, val := range row.vals[n] {
+				indexTxn.Delete(val)
+			}
+		}
+
+		each := func(fn func(key []byte, value interface{})) {
+			for i, row := range rows {
+				if name == versionIndex {
+					fn(row.idVal, versions[i])
+					continue
+				}
+				for _, val := range row.vals[n] {
+					fn(val, row.obj)
+				}
+			}
+		}
+		if !config.sort {
+			each(func(key []byte, value interface{}) {
+				indexTxn.Insert(key, value)
+			})
+			continue
+		}
+
+		// Entries with the same key keep their order so the last one wins
+		type entry struct {
+			key   []byte
+			value interface{}
+			seq   int
+		}
+		count := 0
+		each(func([]byte, interface{}) {
+			count++
+		})
+		entries := make([]entry, 0, count)
+		each(func(key []byte, value interface{}) {
+			entries = append(entries, entry{key, value, len(entries)})
+		})
+		slices.SortFunc(entries, func(a, b entry) int {
+			if c := bytes.Compare(a.key, b.key); c != 0 {
+				return c
+			}
+			return a.seq - b.seq
+		})
+		for _, e := range entries {
+			indexTxn.Insert(e.key, e.value)
+		}
+	}
+
+	// Account for the objects in the usage of the table. Like validators,
+	// quotas aren't enforced.
+	if db.tracksUsage(tableSchema) {
+		usage := txn.usage(table)
+		for _, row := range rows {
+			before, ok := oldRows.Get(row.idVal)
+			if !ok {
+				usage.Rows++
+			}
+			usage.Bytes += db.objectSize(tableSchema, row.obj) - db.objectSize(tableSchema, before)
+		}
+		txn.setUsage(table, usage)
+	}
+
+	// The objects become the most recently used rows, in order
+	if tableSchema.LRU != nil {
+		for _, row := range rows {
+			txn.touch(table, row.idVal, row.obj)
+		}
+	}
+
+	txn.db.commitLock.Lock()
+	txn.commit()
+	if errs != nil {
+		return &BulkLoadError{Table: table, Errors: errs}
+	}
+	return nil
+}
+
+// bulkIndex computes the values of the given indexes of the table for an
+// object, with the primary key appended for non-unique indexes.
+func bulkIndex(tableSchema *TableSchema, names []string, obj interface{}) (*bulkRow, error) {
+	idIndexer := tableSchema.Indexes[id].Indexer.(SingleIndexer)
+	ok, idVal, err := idIndexer.FromObject(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build primary index: %v", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("object missing primary index")
+	}
+
+	row := &bulkRow{
+		obj:   obj,
+		idVal: idVal,
+		vals:  make([][][]byte, len(names)),
+	}
+	for n, name := range names {
+		if name == ttlIndex {
+			key, err := rowExpiry(tableSchema, obj, idVal)
+			if err != nil {
+				return nil, err
+			}
+			if key != nil {
+				row.vals[n] = [][]byte{key}
+			}
+			continue
+		}
+		indexSchema, ok := tableSchema.Indexes[name]
+		if !ok {
+			continue
+		}
+
+		ok, vals, err := indexValues(indexSchema.Indexer, obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build index '%s': %v", name, err)
+		}
+		if !ok {
+			if indexSchema.AllowMissing {
+				continue
+			}
+			return nil, fmt.Errorf("missing value for index '%s'", name)
+		}
+		if !indexSchema.Unique {
+			for i := range vals {
+				vals[i] = append(vals[i], idVal...)
+			}
+		}
+		row.vals[n] = vals
+	}
+	return row, nil
+}
+
+// indexNames returns the names of the indexes of a table loaded by BulkLoad,
+// including the internal ones.
+func indexNames(tableSchema *TableSchema) []string {
+	names := make([]string, 0, len(tableSchema.Indexes)+2)
+	for name := range tableSchema.Indexes {
+		names = append(names, name)
+	}
+	if tableSchema.Versioned {
+		names = append(names, versionIndex)
+	}
+	if tableSchema.TTL != nil {
+		names = append(names, ttlIndex)
+	}
+	return names
+}
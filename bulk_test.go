@@ -0,0 +1,161 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemDB_BulkLoad(t *testing.T) {
+	db := testVersionedDB(t)
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	txn.Commit()
+
+	read := db.Txn(false)
+	watchA, _, err := read.FirstWatch("main", "id", "a")
+	noErr(t, err)
+	tableWatch, _ := read.TableIndexWatch("main")
+
+	for _, sorted := range []bool{false, true} {
+		var opts []BulkLoadOption
+		if sorted {
+			opts = append(opts, BulkLoadSorted())
+		}
+
+		objs := []interface{}{
+			testSavepointObj("c", "y"),
+			&TestObject{ID: "bad", Foo: "y"},
+			testSavepointObj("a", "y"),
+			testSavepointObj("c", "z"),
+		}
+		err := db.BulkLoad("main", objs, opts...)
+		var bErr *BulkLoadError
+		if !errors.As(err, &bErr) || len(bErr.Errors) != 1 || bErr.Errors[1] == nil {
+			t.Fatalf("bad: %v", err)
+		}
+
+		read = db.Txn(false)
+		assertExists(t, read, map[string]bool{"a": true, "b": true, "c": true, "bad": false})
+
+		// Updated objects are removed from their previous index values
+		raw, err := read.First("main", "foo", "x")
+		noErr(t, err)
+		if raw == nil || raw.(*TestObject).ID != "b" {
+			t.Fatalf("bad: %#v", raw)
+		}
+		iter, err := read.Get("main", "foo", "y")
+		noErr(t, err)
+		if raw := iter.Next(); raw == nil || raw.(*TestObject).ID != "a" {
+			t.Fatalf("bad: %#v", raw)
+		}
+		if raw := iter.Next(); raw != nil {
+			t.Fatalf("bad: %#v", raw)
+		}
+		raw, err = read.First("main", "id", "c")
+		noErr(t, err)
+		if raw.(*TestObject).Foo != "z" {
+			t.Fatalf("last object should win: %#v", raw)
+		}
+	}
+
+	// The rows are versioned and the table watch fired, but not the watches
+	// of queries
+	read = db.Txn(false)
+	version, _, err := read.Version("main", testSavepointObj("a", "y"))
+	noErr(t, err)
+	if version != (RowVersion{CreateIndex: 1, ModifyIndex: 3}) {
+		t.Fatalf("bad: %#v", version)
+	}
+	if idx := read.TableIndex("main"); idx != 3 {
+		t.Fatalf("bad: %d", idx)
+	}
+	select {
+	case <-tableWatch:
+	case <-time.After(time.Second):
+		t.Fatalf("table watch should fire")
+	}
+	select {
+	case <-watchA:
+		t.Fatalf("watch of a should not fire")
+	default:
+	}
+
+	if err := db.BulkLoad("nope", nil); err == nil {
+		t.Fatalf("expected error for unknown table")
+	}
+}
+
+func TestMemDB_BulkLoad_Empty(t *testing.T) {
+	db := testDB(t)
+	read := db.Txn(false)
+	tableWatch, _ := read.TableIndexWatch("main")
+
+	// Nothing is committed without any object to load
+	noErr(t, db.BulkLoad("main", nil))
+	var bErr *BulkLoadError
+	if err := db.BulkLoad("main", []interface{}{&TestObject{Foo: "x"}}); !errors.As(err, &bErr) {
+		t.Fatalf("bad: %v", err)
+	}
+	if idx := db.Txn(false).CommitIndex(); idx != 0 {
+		t.Fatalf("bad: %d", idx)
+	}
+	select {
+	case <-tableWatch:
+		t.Fatalf("table watch should not fire")
+	default:
+	}
+}
+
+func benchmarkObjects(n int) []interface{} {
+	objs := make([]interface{}, n)
+	for i := range objs {
+		id := fmt.Sprintf("%08d", (i*7919)%n)
+		objs[i] = &TestObject{ID: id, Foo: id[:4], Qux: []string{id[4:]}}
+	}
+	return objs
+}
+
+func BenchmarkMemDB_Insert(b *testing.B) {
+	objs := benchmarkObjects(100000)
+	for i := 0; i < b.N; i++ {
+		db, _ := NewMemDB(testValidSchema())
+		txn := db.Txn(true)
+		for _, obj := range objs {
+			if err := txn.Insert("main", obj); err != nil {
+				b.Fatalf("err: %v", err)
+			}
+		}
+		txn.Commit()
+	}
+}
+
+func BenchmarkMemDB_BulkLoad(b *testing.B) {
+	objs := benchmarkObjects(100000)
+	for i := 0; i < b.N; i++ {
+		db, _ := NewMemDB(testValidSchema())
+		if err := db.BulkLoad("main", objs, BulkLoadSorted()); err != nil {
+			b.Fatalf("err: %v", err)
+		}
+	}
+}
+
+func BenchmarkMemDB_BulkLoad_Existing(b *testing.B) {
+	db, _ := NewMemDB(testValidSchema())
+	if err := db.BulkLoad("main", benchmarkObjects(100000), BulkLoadSorted()); err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	objs := benchmarkObjects(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := db.BulkLoad("main", objs); err != nil {
+			b.Fatalf("err: %v", err)
+		}
+	}
+}
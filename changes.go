@@ -7,6 +7,20 @@ package memdb
 // transaction.
 type Changes []Change
 
+// Invert returns the changes that undo these changes. The changes are
+// reversed, and the Before and After values of each change are swapped.
+func (c Changes) Invert() Changes {
+	if c == nil {
+		return nil
+	}
+	inverted := make(Changes, len(c))
+	for i, change := range c {
+		change.Before, change.After = change.After, change.Before
+		inverted[len(c)-1-i] = change
+	}
+	return inverted
+}
+
 // Change describes a mutation to an object in a table.
 type Change struct {
 	Table  string
@@ -19,6 +33,12 @@ type Change struct {
 	primaryKey []byte
 }
 
+// PrimaryKey returns the value of the primary index of the mutated object,
+// as computed by the indexer of the "id" index. It must not be modified.
+func (m *Change) PrimaryKey() []byte {
+	return m.primaryKey
+}
+
 // Created returns true if the mutation describes a new object being inserted.
 func (m *Change) Created() bool {
 	return m.Before == nil && m.After != nil
@@ -0,0 +1,90 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"fmt"
+	"sort"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+// Diff returns the changes that turn the contents of old into the contents
+// of new, with the same semantics as Txn.Changes. Changes are ordered by
+// table name and then by primary key.
+//
+// The databases are meant to be snapshots of one another, as returned by
+// Snapshot or TxnAt. Since they share the structure of the radix trees that
+// were not modified between them, the comparison skips the shared subtrees
+// and its cost is proportional to the number of changed rows rather than to
+// the size of the database. Rows are compared by identity rather than by
+// value, so every row present in two unrelated databases is reported as
+// updated, as is a row that was written again with the same object.
+//
+// An error is returned if the databases don't have the same tables.
+func Diff(old, new *MemDB) (Changes, error) {
+	if len(old.schema.Tables) != len(new.schema.Tables) {
+		return nil, fmt.Errorf("databases have different tables")
+	}
+	tables := make([]string, 0, len(new.schema.Tables))
+	for table := range new.schema.Tables {
+		if _, ok := old.schema.Tables[table]; !ok {
+			return nil, fmt.Errorf("table '%s' is not in both databases", table)
+		}
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	oldRoot, newRoot := old.getRoot(), new.getRoot()
+	var changes Changes
+	for _, table := range tables {
+		path := indexPath(table, id)
+		oldIndex, _ := oldRoot.Get(path)
+		newIndex, _ := newRoot.Get(path)
+		d := &differ{
+			table: table,
+			old:   oldIndex.(*iradix.Tree).Root(),
+			new:   newIndex.(*iradix.Tree).Root(),
+		}
+		d.diff(nil)
+		changes = append(changes, d.changes...)
+	}
+	return changes, nil
+}
+
+// differ compares the primary index of a table in two databases.
+type differ struct {
+	table    string
+	old, new *iradix.Node
+	changes  Changes
+}
+
+// diff appends the changes of the keys under the given prefix.
+func (d *differ) diff(prefix []byte) {
+	changedKeys(d.old, d.new, prefix, d.diffKey)
+}
+
+// diffKey appends the change of a single key, if any.
+func (d *differ) diffKey(key []byte) {
+	oldWatch, before, oldOK := d.old.GetWatch(key)
+	newWatch, after, newOK := d.new.GetWatch(key)
+	if oldOK && newOK && oldWatch == newWatch {
+		return
+	}
+	if !oldOK {
+		before = nil
+	}
+	if !newOK {
+		after = nil
+	}
+	if before == nil && after == nil {
+		return
+	}
+	d.changes = append(d.changes, Change{
+		Table:      d.table,
+		Before:     before,
+		After:      after,
+		primaryKey: key,
+	})
+}
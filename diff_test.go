@@ -0,0 +1,153 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testDiff(t *testing.T, old, new *MemDB) Changes {
+	t.Helper()
+	changes, err := Diff(old, new)
+	noErr(t, err)
+	for i := range changes {
+		changes[i].primaryKey = nil
+	}
+	return changes
+}
+
+func TestDiff(t *testing.T) {
+	db := testDB(t)
+	a, b, c := testSavepointObj("a", "x"), testSavepointObj("b", "x"), testSavepointObj("c", "x")
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", a))
+	noErr(t, txn.Insert("main", b))
+	noErr(t, txn.Insert("main", c))
+	txn.Commit()
+	old := db.Snapshot()
+
+	if changes := testDiff(t, old, db); len(changes) != 0 {
+		t.Fatalf("bad: %#v", changes)
+	}
+
+	b2, d := testSavepointObj("b", "y"), testSavepointObj("d", "x")
+	txn = db.Txn(true)
+	noErr(t, txn.Delete("main", a))
+	noErr(t, txn.Insert("main", b2))
+	noErr(t, txn.Insert("main", d))
+	txn.Commit()
+
+	expected := Changes{
+		{Table: "main", Before: a},
+		{Table: "main", Before: b, After: b2},
+		{Table: "main", After: d},
+	}
+	if changes := testDiff(t, old, db); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("bad: %#v", changes)
+	}
+
+	// The reverse diff undoes the changes
+	expected = Changes{
+		{Table: "main", After: a},
+		{Table: "main", Before: b2, After: b},
+		{Table: "main", Before: d},
+	}
+	if changes := testDiff(t, db, old); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("bad: %#v", changes)
+	}
+}
+
+func TestDiff_Prefixes(t *testing.T) {
+	db := testDB(t)
+	txn := db.Txn(true)
+	for _, id := range []string{"a", "ab", "abc", "abd", "b"} {
+		noErr(t, txn.Insert("main", testSavepointObj(id, "x")))
+	}
+	txn.Commit()
+	old := db.Snapshot()
+
+	// Keys that are prefixes of each other split and merge radix nodes
+	abc := testSavepointObj("abc", "y")
+	abcd := testSavepointObj("abcd", "x")
+	txn = db.Txn(true)
+	noErr(t, txn.Delete("main", testSavepointObj("ab", "x")))
+	noErr(t, txn.Insert("main", abc))
+	noErr(t, txn.Insert("main", abcd))
+	txn.Commit()
+
+	changes := testDiff(t, old, db)
+	var ids []string
+	for _, change := range changes {
+		if change.Created() {
+			ids = append(ids, "+"+change.After.(*TestObject).ID)
+		} else if change.Deleted() {
+			ids = append(ids, "-"+change.Before.(*TestObject).ID)
+		} else {
+			ids = append(ids, "~"+change.After.(*TestObject).ID)
+		}
+	}
+	if expected := []string{"-ab", "~abc", "+abcd"}; !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("bad: %v", ids)
+	}
+}
+
+func TestDiff_Tables(t *testing.T) {
+	db, err := NewMemDB(testPreCommitSchema())
+	noErr(t, err)
+	old := db.Snapshot()
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("other", testSavepointObj("a", "x")))
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	txn.Commit()
+
+	changes := testDiff(t, old, db)
+	if len(changes) != 2 || changes[0].Table != "main" || changes[1].Table != "other" {
+		t.Fatalf("bad: %#v", changes)
+	}
+
+	if _, err := Diff(testDB(t), db); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestDiff_Large(t *testing.T) {
+	db := testDB(t)
+	objs := benchmarkObjects(10000)
+	noErr(t, db.BulkLoad("main", objs))
+	old := db.Snapshot()
+
+	txn := db.Txn(true)
+	updated := testSavepointObj("00004242", "x")
+	noErr(t, txn.Insert("main", updated))
+	txn.Commit()
+
+	changes := testDiff(t, old, db)
+	if len(changes) != 1 || changes[0].After != updated {
+		t.Fatalf("bad: %#v", changes)
+	}
+}
+
+func BenchmarkDiff(b *testing.B) {
+	db, _ := NewMemDB(testValidSchema())
+	if err := db.BulkLoad("main", benchmarkObjects(100000)); err != nil {
+		b.Fatalf("err: %v", err)
+	}
+	old := db.Snapshot()
+	txn := db.Txn(true)
+	for _, id := range []string{"00000042", "00050000", "00099999"} {
+		if err := txn.Insert("main", testSavepointObj(id, "x")); err != nil {
+			b.Fatalf("err: %v", err)
+		}
+	}
+	txn.Commit()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if changes, _ := Diff(old, db); len(changes) != 3 {
+			b.Fatalf("bad: %d", len(changes))
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+const (
+	// changesVersion is the version of the encodings of Changes.
+	changesVersion = 1
+)
+
+var (
+	// changesMagic starts the binary encoding of Changes.
+	changesMagic = []byte("mdbc")
+)
+
+// CodecRegistry encodes and decodes Changes so that they can be sent to
+// other processes or persisted. The objects of each table are decoded into
+// the type registered for the table, so every table appearing in the
+// changes must be registered.
+//
+// Two encodings are supported. EncodeJSON produces a self-describing JSON
+// document, with objects encoded by encoding/json. EncodeBinary produces a
+// compact gob stream, where the description of each type is only written
+// once. Both are versioned and keep the primary keys of the changes.
+type CodecRegistry struct {
+	types map[string]reflect.Type
+}
+
+// NewCodecRegistry returns an empty registry.
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{types: make(map[string]reflect.Type)}
+}
+
+// Register sets the type of the objects of a table to the type of the given
+// prototype, usually a pointer to a zero value.
+func (r *CodecRegistry) Register(table string, prototype interface{}) {
+	r.types[table] = reflect.TypeOf(prototype)
+}
+
+// wireChanges is the JSON encoding of Changes.
+type wireChanges struct {
+	Version int          `json:"version"`
+	Changes []wireChange `json:"changes"`
+}
+
+// wireChange is the JSON encoding of a Change.
+type wireChange struct {
+	Table  string          `json:"table"`
+	Key    []byte          `json:"key"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// wireBinaryChange is the binary encoding of a Change. The objects follow
+// it in the stream as flagged by HasBefore and HasAfter.
+type wireBinaryChange struct {
+	Table     string
+	Key       []byte
+	HasBefore bool
+	HasAfter  bool
+}
+
+// wireHeader starts the gob stream of the binary encoding.
+type wireHeader struct {
+	Version int
+	Count   int
+}
+
+// checkType returns an error if an object can't be decoded back.
+func (r *CodecRegistry) checkType(table string, obj interface{}) error {
+	typ, ok := r.types[table]
+	if !ok {
+		return fmt.Errorf("no type registered for table '%s'", table)
+	}
+	if obj != nil && reflect.TypeOf(obj) != typ {
+		return fmt.Errorf("object of type %T doesn't match type %v registered for table '%s'", obj, typ, table)
+	}
+	return nil
+}
+
+// decodeValue returns a pointer to a new value of the registered type of a
+// table.
+func (r *CodecRegistry) decodeValue(table string) (reflect.Value, error) {
+	typ, ok := r.types[table]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("no type registered for table '%s'", table)
+	}
+	return reflect.New(typ), nil
+}
+
+// EncodeJSON encodes changes as JSON.
+func (r *CodecRegistry) EncodeJSON(changes Changes) ([]byte, error) {
+	wire := wireChanges{
+		Version: changesVersion,
+		Changes: make([]wireChange, 0, len(changes)),
+	}
+	for _, change := range changes {
+		w := wireChange{Table: change.Table, Key: change.primaryKey}
+		for _, obj := range []interface{}{change.Before, change.After} {
+			if err := r.checkType(change.Table, obj); err != nil {
+				return nil, err
+			}
+		}
+		var err error
+		if change.Before != nil {
+			if w.Before, err = json.Marshal(change.Before); err != nil {
+				return nil, err
+			}
+		}
+		if change.After != nil {
+			if w.After, err = json.Marshal(change.After); err != nil {
+				return nil, err
+			}
+		}
+		wire.Changes = append(wire.Changes, w)
+	}
+	return json.Marshal(&wire)
+}
+
+// DecodeJSON decodes changes encoded by EncodeJSON.
+func (r *CodecRegistry) DecodeJSON(data []byte) (Changes, error) {
+	var wire wireChanges
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return nil, err
+	}
+	if wire.Version != changesVersion {
+		return nil, fmt.Errorf("unsupported changes version %d", wire.Version)
+	}
+
+	changes := make(Changes, 0, len(wire.Changes))
+	for _, w := range wire.Changes {
+		change := Change{Table: w.Table, primaryKey: w.Key}
+		for _, obj := range []struct {
+			raw json.RawMessage
+			dst *interface{}
+		}{{w.Before, &change.Before}, {w.After, &change.After}} {
+			if obj.raw == nil {
+				continue
+			}
+			val, err := r.decodeValue(w.Table)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(obj.raw, val.Interface()); err != nil {
+				return nil, fmt.Errorf("failed to decode object of table '%s': %v", w.Table, err)
+			}
+			*obj.dst = val.Elem().Interface()
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// EncodeBinary encodes changes in the compact binary encoding.
+func (r *CodecRegistry) EncodeBinary(changes Changes) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(changesMagic)
+	enc := gob.NewEncoder(&buf)
+	if err := enc.Encode(&wireHeader{Version: changesVersion, Count: len(changes)}); err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		w := wireBinaryChange{
+			Table:     change.Table,
+			Key:       change.primaryKey,
+			HasBefore: change.Before != nil,
+			HasAfter:  change.After != nil,
+		}
+		if err := enc.Encode(&w); err != nil {
+			return nil, err
+		}
+		for _, obj := range []interface{}{change.Before, change.After} {
+			if err := r.checkType(change.Table, obj); err != nil {
+				return nil, err
+			}
+			if obj == nil {
+				continue
+			}
+			if err := enc.Encode(obj); err != nil {
+				return nil, fmt.Errorf("failed to encode object of table '%s': %v", change.Table, err)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeBinary decodes changes encoded by EncodeBinary.
+func (r *CodecRegistry) DecodeBinary(data []byte) (Changes, error) {
+	if !bytes.HasPrefix(data, changesMagic) {
+		return nil, fmt.Errorf("invalid encoding of changes")
+	}
+	dec := gob.NewDecoder(bytes.NewReader(data[len(changesMagic):]))
+
+	var header wireHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Version != changesVersion {
+		return nil, fmt.Errorf("unsupported changes version %d", header.Version)
+	}
+	if header.Count < 0 || header.Count > len(data) {
+		return nil, fmt.Errorf("invalid encoding of changes")
+	}
+
+	changes := make(Changes, 0, header.Count)
+	for i := 0; i < header.Count; i++ {
+		var w wireBinaryChange
+		if err := dec.Decode(&w); err != nil {
+			return nil, err
+		}
+		change := Change{Table: w.Table, primaryKey: w.Key}
+		for _, obj := range []struct {
+			present bool
+			dst     *interface{}
+		}{{w.HasBefore, &change.Before}, {w.HasAfter, &change.After}} {
+			if !obj.present {
+				continue
+			}
+			val, err := r.decodeValue(w.Table)
+			if err != nil {
+				return nil, err
+			}
+			if err := dec.DecodeValue(val); err != nil {
+				return nil, fmt.Errorf("failed to decode object of table '%s': %v", w.Table, err)
+			}
+			*obj.dst = val.Elem().Interface()
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
@@ -0,0 +1,140 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testEncodingChanges(t *testing.T) Changes {
+	t.Helper()
+	db, err := NewMemDB(testPreCommitSchema())
+	noErr(t, err)
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	txn.Commit()
+
+	txn = db.Txn(true)
+	txn.TrackChanges()
+	fu := "fu"
+	updated := testSavepointObj("a", "y")
+	updated.Fu = &fu
+	updated.Zod = map[string]string{"k": "v"}
+	noErr(t, txn.Insert("main", updated))
+	noErr(t, txn.Delete("main", testSavepointObj("b", "x")))
+	noErr(t, txn.Insert("other", testSavepointObj("c", "x")))
+	txn.Commit()
+	return txn.Changes()
+}
+
+func testCodecRegistry() *CodecRegistry {
+	r := NewCodecRegistry()
+	r.Register("main", &TestObject{})
+	r.Register("other", &TestObject{})
+	return r
+}
+
+func TestCodecRegistry_RoundTrip(t *testing.T) {
+	changes := testEncodingChanges(t)
+	if len(changes) != 3 {
+		t.Fatalf("bad: %#v", changes)
+	}
+	r := testCodecRegistry()
+
+	for name, codec := range map[string]struct {
+		encode func(Changes) ([]byte, error)
+		decode func([]byte) (Changes, error)
+	}{
+		"json":   {r.EncodeJSON, r.DecodeJSON},
+		"binary": {r.EncodeBinary, r.DecodeBinary},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.encode(changes)
+			noErr(t, err)
+			decoded, err := codec.decode(data)
+			noErr(t, err)
+			if !reflect.DeepEqual(decoded, changes) {
+				t.Fatalf("bad: %#v", decoded)
+			}
+			if !bytes.Equal(decoded[0].PrimaryKey(), []byte("a\x00")) {
+				t.Fatalf("bad: %q", decoded[0].PrimaryKey())
+			}
+
+			// Empty change sets round-trip too
+			data, err = codec.encode(nil)
+			noErr(t, err)
+			decoded, err = codec.decode(data)
+			noErr(t, err)
+			if len(decoded) != 0 {
+				t.Fatalf("bad: %#v", decoded)
+			}
+		})
+	}
+}
+
+func TestCodecRegistry_Binary_Compact(t *testing.T) {
+	var changes Changes
+	for i := 0; i < 100; i++ {
+		obj := testSavepointObj(strings.Repeat("x", i), "foo")
+		changes = append(changes, Change{Table: "main", After: obj, primaryKey: []byte(obj.ID)})
+	}
+	r := testCodecRegistry()
+
+	jsonData, err := r.EncodeJSON(changes)
+	noErr(t, err)
+	binData, err := r.EncodeBinary(changes)
+	noErr(t, err)
+	if len(binData) >= len(jsonData)/2 {
+		t.Fatalf("binary encoding isn't compact: %d vs %d bytes", len(binData), len(jsonData))
+	}
+}
+
+func TestCodecRegistry_Errors(t *testing.T) {
+	changes := testEncodingChanges(t)
+
+	// Unregistered tables and mismatched types can't be encoded
+	r := NewCodecRegistry()
+	r.Register("main", &TestObject{})
+	if _, err := r.EncodeJSON(changes); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := r.EncodeBinary(changes); err == nil {
+		t.Fatalf("expected error")
+	}
+	r.Register("other", TestObject{})
+	if _, err := r.EncodeJSON(changes); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// Unregistered tables can't be decoded
+	full := testCodecRegistry()
+	jsonData, err := full.EncodeJSON(changes)
+	noErr(t, err)
+	binData, err := full.EncodeBinary(changes)
+	noErr(t, err)
+	r = NewCodecRegistry()
+	r.Register("main", &TestObject{})
+	if _, err := r.DecodeJSON(jsonData); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := r.DecodeBinary(binData); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// Unknown versions and garbage are rejected
+	if _, err := full.DecodeJSON([]byte(`{"version":2,"changes":[]}`)); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := full.DecodeBinary([]byte("garbage")); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := full.DecodeBinary(binData[:len(binData)-3]); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrSubscriptionClosed is returned by Subscription.Next once the
+	// subscription was closed.
+	ErrSubscriptionClosed = fmt.Errorf("subscription closed")
+)
+
+// WithEvents enables the event publisher of the database, see Subscribe.
+// The changes of the last replay commits are kept so that subscribers can
+// resume after a given commit index, and each subscription buffers at most
+// queue events before it is reset.
+//
+// Every write transaction tracks its changes once events are enabled, as if
+// TrackChanges had been called.
+func WithEvents(replay, queue int) Option {
+	return func(db *MemDB) {
+		db.events = &publisher{id: newPublisherID(), replay: replay, queue: queue}
+	}
+}
+
+// Topic selects the changes delivered to a subscription. An empty Index
+// selects every change of the table. Otherwise only the changes of objects
+// whose value of the index matches Args, before or after the change, are
+// selected.
+type Topic struct {
+	Table string
+	Index string
+	Args  []interface{}
+}
+
+// Event holds the changes made by a committed transaction to the topics of
+// a subscription.
+type Event struct {
+	// Index is the commit index of the transaction, as returned by
+	// Txn.CommitIndex after it committed. Indexes increase from one event to
+	// the next but aren't contiguous since commits without any change to the
+	// topics don't produce an event.
+	Index uint64
+
+	// Changes holds the changes of the transaction, with the same semantics
+	// as Txn.Changes.
+	Changes Changes
+
+	// Reset is set when events were dropped, either because the subscriber
+	// didn't keep up or because it resumed after an index that is no longer
+	// replayable. The subscriber should read the state of the database again
+	// from a transaction whose commit index is at least Index. Following
+	// events are only those committed after Index.
+	Reset bool
+}
+
+// SubscribeOption configures Subscribe.
+type SubscribeOption func(*subscribeConfig)
+
+type subscribeConfig struct {
+	resume bool
+	index  uint64
+}
+
+// ResumeAfter makes Subscribe deliver the events committed after the given
+// commit index, which is usually the commit index of the transaction the
+// subscriber read its initial state from. If they are no longer kept, the
+// first event is a reset.
+func ResumeAfter(index uint64) SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.resume = true
+		c.index = index
+	}
+}
+
+// Subscription delivers the events of its topics in commit order.
+type Subscription struct {
+	pub    *publisher
+	topics []subscriptionTopic
+	stop   func() bool
+
+	l      sync.Mutex
+	queue  []Event
+	ready  chan struct{}
+	closed error
+}
+
+// subscriptionTopic is a topic with its index resolved.
+type subscriptionTopic struct {
+	table   string
+	indexer Indexer
+	key     []byte
+}
+
+// publisher delivers the changes of every commit to the subscriptions.
+type publisher struct {
+	// id identifies the history of commits of the database, so that a
+	// follower of another database can tell it must start over.
+	id     [16]byte
+	replay int
+	queue  int
+
+	l      sync.Mutex
+	index  uint64
+	buffer []Event
+	subs   map[*Subscription]struct{}
+}
+
+// Subscribe starts delivering the changes of the given topics, committed
+// from now on or after the index given with ResumeAfter. Without topics,
+// every change is delivered. Publishing never waits for subscribers: once a
+// subscription has queued too many events, they are replaced by a reset
+// event.
+//
+// The subscription is closed when the context is done or Close is called.
+// An error is returned if events aren't enabled, see WithEvents, or if a
+// topic is invalid.
+func (db *MemDB) Subscribe(ctx context.Context, topics []Topic, opts ...SubscribeOption) (*Subscription, error) {
+	if db.events == nil {
+		return nil, fmt.Errorf("events are not enabled")
+	}
+
+	var config subscribeConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	sub := &Subscription{
+		pub:   db.events,
+		ready: make(chan struct{}, 1),
+	}
+	for _, topic := range topics {
+		resolved, err := db.resolveTopic(topic)
+		if err != nil {
+			return nil, err
+		}
+		sub.topics = append(sub.topics, resolved)
+	}
+
+	if err := db.events.subscribe(sub, config); err != nil {
+		return nil, err
+	}
+	sub.stop = context.AfterFunc(ctx, func() { sub.close(ctx.Err()) })
+	return sub, nil
+}
+
+// resolveTopic looks up the table and index of a topic.
+func (db *MemDB) resolveTopic(topic Topic) (subscriptionTopic, error) {
+	tableSchema, ok := db.schema.Tables[topic.Table]
+	if !ok {
+		return subscriptionTopic{}, fmt.Errorf("invalid table '%s'", topic.Table)
+	}
+	resolved := subscriptionTopic{table: topic.Table}
+	if topic.Index == "" {
+		return resolved, nil
+	}
+
+	indexSchema, ok := tableSchema.Indexes[topic.Index]
+	if !ok {
+		return subscriptionTopic{}, fmt.Errorf("invalid index '%s'", topic.Index)
+	}
+	key, err := indexSchema.Indexer.FromArgs(topic.Args...)
+	if err != nil {
+		return subscriptionTopic{}, fmt.Errorf("index error: %v", err)
+	}
+	resolved.indexer = indexSchema.Indexer
+	resolved.key = key
+	return resolved, nil
+}
+
+// Next returns the next event, waiting for one to be published if needed.
+// Once the subscription is closed, the remaining events are dropped and
+// the context error or ErrSubscriptionClosed is returned.
+func (s *Subscription) Next() (Event, error) {
+	for {
+		s.l.Lock()
+		if s.closed != nil {
+			s.l.Unlock()
+			return Event{}, s.closed
+		}
+		if len(s.queue) > 0 {
+			event := s.queue[0]
+			s.queue[0] = Event{}
+			s.queue = s.queue[1:]
+			s.l.Unlock()
+			return event, nil
+		}
+		s.l.Unlock()
+
+		<-s.ready
+	}
+}
+
+// Close stops the delivery of events to the subscription.
+func (s *Subscription) Close() {
+	s.stop()
+	s.close(ErrSubscriptionClosed)
+}
+
+func (s *Subscription) close(err error) {
+	s.pub.unsubscribe(s)
+
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.closed == nil {
+		s.closed = err
+		s.queue = nil
+		s.signal()
+	}
+}
+
+// signal wakes up Next. The lock must be held.
+func (s *Subscription) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// filter returns the event holding the changes selected by the topics of
+// the subscription, and whether there are any.
+func (s *Subscription) filter(event Event) (Event, bool) {
+	var changes Changes
+	for _, change := range event.Changes {
+		if s.matches(change) {
+			changes = append(changes, change)
+		}
+	}
+	if len(changes) == 0 {
+		return Event{}, false
+	}
+	return Event{Index: event.Index, Changes: changes}, true
+}
+
+// matches returns whether a change is selected by any of the topics.
+func (s *Subscription) matches(change Change) bool {
+	if len(s.topics) == 0 {
+		return true
+	}
+	for _, topic := range s.topics {
+		if topic.table != change.Table {
+			continue
+		}
+		if topic.indexer == nil {
+			return true
+		}
+		for _, obj := range []interface{}{change.Before, change.After} {
+			if obj == nil {
+				continue
+			}
+			ok, vals, err := indexValues(topic.indexer, obj)
+			if err != nil || !ok {
+				continue
+			}
+			for _, val := range vals {
+				if bytes.Equal(val, topic.key) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// push queues an event, replacing the queued events with a reset event if
+// there are too many.
+func (s *Subscription) push(event Event, limit int) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.closed != nil {
+		return
+	}
+	if limit > 0 && len(s.queue) >= limit {
+		s.queue = append(s.queue[:0], Event{Index: event.Index, Reset: true})
+	} else {
+		s.queue = append(s.queue, event)
+	}
+	s.signal()
+}
+
+// snapshot returns a publisher with the same limits, starting at the
+// given commit index.
+func (p *publisher) snapshot(index uint64) *publisher {
+	return &publisher{id: newPublisherID(), replay: p.replay, queue: p.queue, index: index}
+}
+
+// newPublisherID returns a random publisher ID.
+func newPublisherID() [16]byte {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// subscribe registers a subscription and queues the events it resumes
+// from.
+func (p *publisher) subscribe(sub *Subscription, config subscribeConfig) error {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	if config.resume {
+		if config.index > p.index {
+			return fmt.Errorf("commit index %d is ahead of the database at %d", config.index, p.index)
+		}
+
+		// The buffer holds the events of consecutive commits
+		if config.index < p.index {
+			if len(p.buffer) == 0 || p.buffer[0].Index > config.index+1 {
+				sub.push(Event{Index: p.index, Reset: true}, p.queue)
+			} else {
+				for _, event := range p.buffer {
+					if event.Index <= config.index {
+						continue
+					}
+					if filtered, ok := sub.filter(event); ok {
+						sub.push(filtered, p.queue)
+					}
+				}
+			}
+		}
+	}
+
+	if p.subs == nil {
+		p.subs = make(map[*Subscription]struct{})
+	}
+	p.subs[sub] = struct{}{}
+	return nil
+}
+
+func (p *publisher) unsubscribe(sub *Subscription) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	delete(p.subs, sub)
+}
+
+// publish delivers the changes of a commit. It must be called in commit
+// order.
+func (p *publisher) publish(index uint64, changes Changes) {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	event := Event{Index: index, Changes: changes}
+	p.index = index
+	if p.replay > 0 {
+		if len(p.buffer) >= p.replay {
+			p.buffer[0] = Event{}
+			p.buffer = p.buffer[1:]
+		}
+		p.buffer = append(p.buffer, event)
+	}
+
+	for sub := range p.subs {
+		if filtered, ok := sub.filter(event); ok {
+			sub.push(filtered, p.queue)
+		}
+	}
+}
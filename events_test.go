@@ -0,0 +1,242 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testEventsDB(t *testing.T, replay, queue int) *MemDB {
+	t.Helper()
+	db, err := NewMemDB(testValidSchema(), WithEvents(replay, queue))
+	noErr(t, err)
+	return db
+}
+
+func testNextEvent(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	type result struct {
+		event Event
+		err   error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		event, err := sub.Next()
+		ch <- result{event, err}
+	}()
+	select {
+	case res := <-ch:
+		noErr(t, res.err)
+		return res.event
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for event")
+		return Event{}
+	}
+}
+
+func testEventIDs(event Event) []string {
+	var ids []string
+	for _, change := range event.Changes {
+		obj := change.After
+		if obj == nil {
+			obj = change.Before
+		}
+		ids = append(ids, obj.(*TestObject).ID)
+	}
+	return ids
+}
+
+func TestMemDB_Subscribe(t *testing.T) {
+	db := testEventsDB(t, 10, 10)
+	sub, err := db.Subscribe(context.Background(), nil)
+	noErr(t, err)
+	defer sub.Close()
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	noErr(t, txn.Insert("main", testSavepointObj("a", "y")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	txn.Commit()
+	index := db.Txn(false).CommitIndex()
+
+	event := testNextEvent(t, sub)
+	if event.Index != index || event.Reset {
+		t.Fatalf("bad: %#v", event)
+	}
+
+	// Changes are de-duplicated
+	if len(event.Changes) != 2 || !event.Changes[0].Created() ||
+		event.Changes[0].After.(*TestObject).Foo != "y" {
+		t.Fatalf("bad: %#v", event.Changes)
+	}
+
+	txn = db.Txn(true)
+	noErr(t, txn.Delete("main", testSavepointObj("a", "y")))
+	txn.Commit()
+
+	event = testNextEvent(t, sub)
+	if event.Index != index+1 || len(event.Changes) != 1 || !event.Changes[0].Deleted() {
+		t.Fatalf("bad: %#v", event)
+	}
+}
+
+func TestMemDB_Subscribe_Topics(t *testing.T) {
+	db, err := NewMemDB(testPreCommitSchema(), WithEvents(10, 10))
+	noErr(t, err)
+	sub, err := db.Subscribe(context.Background(), []Topic{
+		{Table: "main", Index: "foo", Args: []interface{}{"x"}},
+		{Table: "other"},
+	})
+	noErr(t, err)
+	defer sub.Close()
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "y")))
+	noErr(t, txn.Insert("other", testSavepointObj("c", "y")))
+	txn.Commit()
+
+	// A commit without any change to the topics doesn't produce an event
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("d", "y")))
+	txn.Commit()
+
+	// Objects moving out of the topic are selected by their before value
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "z")))
+	txn.Commit()
+
+	event := testNextEvent(t, sub)
+	if ids := testEventIDs(event); len(ids) != 2 || ids[0] != "a" || ids[1] != "c" {
+		t.Fatalf("bad: %v", ids)
+	}
+	next := testNextEvent(t, sub)
+	if next.Index != event.Index+2 || !next.Changes[0].Updated() {
+		t.Fatalf("bad: %#v", next)
+	}
+}
+
+func TestMemDB_Subscribe_Resume(t *testing.T) {
+	db := testEventsDB(t, 2, 10)
+	for _, id := range []string{"a", "b", "c"} {
+		txn := db.Txn(true)
+		noErr(t, txn.Insert("main", testSavepointObj(id, "x")))
+		txn.Commit()
+	}
+
+	sub, err := db.Subscribe(context.Background(), nil, ResumeAfter(1))
+	noErr(t, err)
+	defer sub.Close()
+	for _, id := range []string{"b", "c"} {
+		if ids := testEventIDs(testNextEvent(t, sub)); len(ids) != 1 || ids[0] != id {
+			t.Fatalf("bad: %v", ids)
+		}
+	}
+
+	// Resuming from an index that is no longer replayable resets
+	old, err := db.Subscribe(context.Background(), nil, ResumeAfter(0))
+	noErr(t, err)
+	defer old.Close()
+	if event := testNextEvent(t, old); !event.Reset || event.Index != 3 {
+		t.Fatalf("bad: %#v", event)
+	}
+
+	if _, err := db.Subscribe(context.Background(), nil, ResumeAfter(4)); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestMemDB_Subscribe_SlowConsumer(t *testing.T) {
+	db := testEventsDB(t, 0, 2)
+	sub, err := db.Subscribe(context.Background(), nil)
+	noErr(t, err)
+	defer sub.Close()
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		txn := db.Txn(true)
+		noErr(t, txn.Insert("main", testSavepointObj(id, "x")))
+		txn.Commit()
+	}
+
+	// The third event replaced the queue with a reset
+	if event := testNextEvent(t, sub); !event.Reset || event.Index != 3 {
+		t.Fatalf("bad: %#v", event)
+	}
+	if ids := testEventIDs(testNextEvent(t, sub)); len(ids) != 1 || ids[0] != "d" {
+		t.Fatalf("bad: %v", ids)
+	}
+}
+
+func TestMemDB_Subscribe_Close(t *testing.T) {
+	db := testEventsDB(t, 0, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := db.Subscribe(ctx, nil)
+	noErr(t, err)
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := sub.Next()
+		errCh <- err
+	}()
+	cancel()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("bad: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out")
+	}
+
+	sub, err = db.Subscribe(context.Background(), nil)
+	noErr(t, err)
+	sub.Close()
+	if _, err := sub.Next(); !errors.Is(err, ErrSubscriptionClosed) {
+		t.Fatalf("bad: %v", err)
+	}
+
+	if n := len(db.events.subs); n != 0 {
+		t.Fatalf("bad: %d", n)
+	}
+}
+
+func TestMemDB_Subscribe_Invalid(t *testing.T) {
+	if _, err := testDB(t).Subscribe(context.Background(), nil); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	db := testEventsDB(t, 0, 10)
+	for _, topic := range []Topic{
+		{Table: "nope"},
+		{Table: "main", Index: "nope"},
+		{Table: "main", Index: "foo", Args: []interface{}{1}},
+	} {
+		if _, err := db.Subscribe(context.Background(), []Topic{topic}); err == nil {
+			t.Fatalf("expected error for %#v", topic)
+		}
+	}
+}
+
+func TestMemDB_Subscribe_BulkLoad(t *testing.T) {
+	db := testEventsDB(t, 0, 10)
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	txn.Commit()
+
+	sub, err := db.Subscribe(context.Background(), nil)
+	noErr(t, err)
+	defer sub.Close()
+
+	noErr(t, db.BulkLoad("main", []interface{}{
+		testSavepointObj("a", "y"),
+		testSavepointObj("b", "y"),
+	}))
+	event := testNextEvent(t, sub)
+	if len(event.Changes) != 2 || !event.Changes[0].Updated() || !event.Changes[1].Created() {
+		t.Fatalf("bad: %#v", event.Changes)
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// ForeignKeyError is returned when a write would violate a foreign key.
+type ForeignKeyError struct {
+	// Table is the referencing table and ForeignKey is the name of the
+	// violated foreign key within it.
+	Table      string
+	ForeignKey string
+
+	// Reason describes the violation.
+	Reason string
+}
+
+func (e *ForeignKeyError) Error() string {
+	return fmt.Sprintf("foreign key %q on table %q: %s", e.ForeignKey, e.Table, e.Reason)
+}
+
+// foreignKey is a ForeignKeySchema resolved against the DBSchema.
+type foreignKey struct {
+	*ForeignKeySchema
+
+	// table is the referencing table.
+	table string
+
+	// indexer extracts the references from a referencing row.
+	indexer Indexer
+}
+
+// foreignKeyAction is an action to take on the referencing rows of a deleted
+// row.
+type foreignKeyAction struct {
+	fk   *foreignKey
+	rows []interface{}
+}
+
+// indexer returns the Indexer used to extract references from the rows of
+// the referencing table.
+func (s *ForeignKeySchema) indexer(table *TableSchema, foreign *IndexSchema) (Indexer, error) {
+	if s.Index != "" {
+		return table.Indexes[s.Index].Indexer, nil
+	}
+	if s.Indexer != nil {
+		return s.Indexer, nil
+	}
+
+	// Derive an indexer for the field from the foreign index so that both
+	// sides use the same encoding.
+	switch indexer := foreign.Indexer.(type) {
+	case *StringFieldIndex:
+		return &StringFieldIndex{Field: s.Field, Lowercase: indexer.Lowercase}, nil
+	case *UUIDFieldIndex:
+		return &UUIDFieldIndex{Field: s.Field}, nil
+	case *IntFieldIndex:
+		return &IntFieldIndex{Field: s.Field}, nil
+	case *UintFieldIndex:
+		return &UintFieldIndex{Field: s.Field}, nil
+	case *BoolFieldIndex:
+		return &BoolFieldIndex{Field: s.Field}, nil
+	default:
+		return nil, fmt.Errorf("cannot derive an indexer for field '%s' from foreign index '%s'", s.Field, foreign.Name)
+	}
+}
+
+// zeroIsMissing returns whether an indexer extracting references returns no
+// value for the zero value of its field. The built-in integer and boolean
+// field indexers index the zero value like any other, and other indexers
+// are assumed to treat it as missing.
+func zeroIsMissing(indexer Indexer) bool {
+	switch indexer.(type) {
+	case *IntFieldIndex, *UintFieldIndex, *BoolFieldIndex:
+		return false
+	default:
+		return true
+	}
+}
+
+// resolveForeignKeys indexes the foreign keys of the schema by referencing
+// and by referenced table. The schema must already be validated.
+func resolveForeignKeys(schema *DBSchema) (map[string][]*foreignKey, map[string][]*foreignKey) {
+	var byTable, byForeign map[string][]*foreignKey
+	for name, table := range schema.Tables {
+		for _, fkSchema := range table.ForeignKeys {
+			foreign := schema.Tables[fkSchema.ForeignTable].Indexes[fkSchema.ForeignIndex]
+			indexer, _ := fkSchema.indexer(table, foreign)
+			fk := &foreignKey{
+				ForeignKeySchema: fkSchema,
+				table:            name,
+				indexer:          indexer,
+			}
+
+			if byTable == nil {
+				byTable = make(map[string][]*foreignKey)
+				byForeign = make(map[string][]*foreignKey)
+			}
+			byTable[name] = append(byTable[name], fk)
+			byForeign[fk.ForeignTable] = append(byForeign[fk.ForeignTable], fk)
+		}
+	}
+	return byTable, byForeign
+}
+
+// checkForeignKeys verifies that every reference made by obj points at an
+// existing row, and that an update of an existing row doesn't remove values
+// that are still referenced by other rows.
+func (txn *Txn) checkForeignKeys(table string, existing, obj interface{}) error {
+	if txn.replica || txn.applying {
+		return nil
+	}
+	for _, fk := range txn.db.foreignKeys[table] {
+		if err := txn.checkLocked(fk.ForeignTable); err != nil {
+			return err
+		}
+		ok, vals, err := indexValues(fk.indexer, obj)
+		if err != nil {
+			return fmt.Errorf("failed to build foreign key '%s': %v", fk.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		for _, val := range vals {
+			if _, found := txn.indexGet(fk.ForeignTable, fk.ForeignIndex, val); !found {
+				return &ForeignKeyError{
+					Table:      table,
+					ForeignKey: fk.Name,
+					Reason:     fmt.Sprintf("referenced row not found in table %q", fk.ForeignTable),
+				}
+			}
+		}
+	}
+
+	if existing == nil {
+		return nil
+	}
+
+	// Values that are no longer produced by the updated row would orphan
+	// their referencing rows, so they are always restricted.
+	for _, fk := range txn.db.references[table] {
+		if err := txn.checkLocked(fk.table); err != nil {
+			return err
+		}
+		foreignIndexer := txn.db.schema.Tables[table].Indexes[fk.ForeignIndex].Indexer
+		removed, err := removedIndexValues(foreignIndexer, existing, obj)
+		if err != nil {
+			return fmt.Errorf("failed to build index '%s': %v", fk.ForeignIndex, err)
+		}
+		if len(removed) == 0 {
+			continue
+		}
+		rows, err := txn.referencingRows(fk, removed)
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			return &ForeignKeyError{
+				Table:      fk.table,
+				ForeignKey: fk.Name,
+				Reason:     fmt.Sprintf("referenced row in table %q is still referenced", table),
+			}
+		}
+	}
+	return nil
+}
+
+// foreignKeyActions returns the actions to take on the rows referencing an
+// object that is about to be deleted. An error is returned if a restricting
+// foreign key still has referencing rows.
+func (txn *Txn) foreignKeyActions(table string, existing interface{}) ([]foreignKeyAction, error) {
+	if txn.replica || txn.applying {
+		return nil, nil
+	}
+	var actions []foreignKeyAction
+	for _, fk := range txn.db.references[table] {
+		if err := txn.checkLocked(fk.table); err != nil {
+			return nil, err
+		}
+		foreignIndexer := txn.db.schema.Tables[table].Indexes[fk.ForeignIndex].Indexer
+		ok, vals, err := indexValues(foreignIndexer, existing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build index '%s': %v", fk.ForeignIndex, err)
+		}
+		if !ok {
+			continue
+		}
+		rows, err := txn.referencingRows(fk, vals)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		if fk.OnDelete == Restrict {
+			return nil, &ForeignKeyError{
+				Table:      fk.table,
+				ForeignKey: fk.Name,
+				Reason:     fmt.Sprintf("referenced row in table %q is still referenced", table),
+			}
+		}
+		actions = append(actions, foreignKeyAction{fk: fk, rows: rows})
+	}
+	return actions, nil
+}
+
+// applyForeignKeyActions cascades a delete to the referencing rows.
+func (txn *Txn) applyForeignKeyActions(actions []foreignKeyAction) error {
+	for _, action := range actions {
+		for _, row := range action.rows {
+			switch action.fk.OnDelete {
+			case Cascade:
+				// The row may already be gone through another cascade
+				if err := txn.Delete(action.fk.table, row); err != nil && err != ErrNotFound {
+					return err
+				}
+			case SetNull:
+				cleared, err := clearField(row, action.fk.Field)
+				if err != nil {
+					return &ForeignKeyError{
+						Table:      action.fk.table,
+						ForeignKey: action.fk.Name,
+						Reason:     err.Error(),
+					}
+				}
+				if err := txn.Insert(action.fk.table, cleared); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// referencingRows returns the rows of the referencing table of fk that
+// reference any of the given values.
+func (txn *Txn) referencingRows(fk *foreignKey, vals [][]byte) ([]interface{}, error) {
+	references := func(obj interface{}) (bool, error) {
+		ok, refs, err := indexValues(fk.indexer, obj)
+		if err != nil || !ok {
+			return false, err
+		}
+		for _, ref := range refs {
+			for _, val := range vals {
+				if bytes.Equal(ref, val) {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+
+	var rows []interface{}
+	seen := make(map[string]struct{})
+	add := func(obj interface{}) error {
+		ok, err := references(obj)
+		if err != nil {
+			return fmt.Errorf("failed to build foreign key '%s': %v", fk.Name, err)
+		}
+		if !ok {
+			return nil
+		}
+
+		// A multi-valued reference may be found more than once
+		idVal, err := txn.primaryKey(fk.table, obj)
+		if err != nil {
+			return err
+		}
+		if _, dup := seen[string(idVal)]; !dup {
+			seen[string(idVal)] = struct{}{}
+			rows = append(rows, obj)
+		}
+		return nil
+	}
+
+	// Without an index the whole referencing table has to be scanned
+	if fk.Index == "" {
+		txn.recordRead(fk.table, id, nil)
+		iter := txn.readableIndex(fk.table, id).Root().Iterator()
+		for _, obj, ok := iter.Next(); ok; _, obj, ok = iter.Next() {
+			if err := add(obj); err != nil {
+				return nil, err
+			}
+		}
+		return rows, nil
+	}
+
+	indexTxn := txn.readableIndex(fk.table, fk.Index)
+	for _, val := range vals {
+		txn.recordRead(fk.table, fk.Index, val)
+		iter := indexTxn.Root().Iterator()
+		iter.SeekPrefix(val)
+		for _, obj, ok := iter.Next(); ok; _, obj, ok = iter.Next() {
+			if err := add(obj); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rows, nil
+}
+
+// removedIndexValues returns the values produced by the indexer for before
+// that are no longer produced for after.
+func removedIndexValues(indexer Indexer, before, after interface{}) ([][]byte, error) {
+	ok, beforeVals, err := indexValues(indexer, before)
+	if err != nil || !ok {
+		return nil, err
+	}
+	ok, afterVals, err := indexValues(indexer, after)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return beforeVals, nil
+	}
+
+	var removed [][]byte
+OUTER:
+	for _, val := range beforeVals {
+		for _, afterVal := range afterVals {
+			if bytes.Equal(val, afterVal) {
+				continue OUTER
+			}
+		}
+		removed = append(removed, val)
+	}
+	return removed, nil
+}
+
+// clearField returns a shallow copy of obj with the named field set to its
+// zero value. obj must be a struct or a pointer to a struct.
+func clearField(obj interface{}, field string) (interface{}, error) {
+	v := reflect.ValueOf(obj)
+	isPtr := v.Kind() == reflect.Ptr
+	v = reflect.Indirect(v)
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot clear field '%s' of %#v", field, obj)
+	}
+
+	cp := reflect.New(v.Type()).Elem()
+	cp.Set(v)
+	fv := cp.FieldByName(field)
+	if !fv.IsValid() || !fv.CanSet() {
+		return nil, fmt.Errorf("field '%s' for %#v is invalid", field, obj)
+	}
+	fv.Set(reflect.Zero(fv.Type()))
+
+	if isPtr {
+		return cp.Addr().Interface(), nil
+	}
+	return cp.Interface(), nil
+}
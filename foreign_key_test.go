@@ -0,0 +1,349 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"errors"
+	"testing"
+)
+
+type TestNode struct {
+	ID   string
+	Name string
+}
+
+type TestAlloc struct {
+	ID       string
+	NodeID   string
+	NodeName string
+}
+
+func testForeignKeySchema(onDelete ForeignKeyAction) *DBSchema {
+	return &DBSchema{
+		Tables: map[string]*TableSchema{
+			"nodes": &TableSchema{
+				Name: "nodes",
+				Indexes: map[string]*IndexSchema{
+					"id": &IndexSchema{
+						Name:    "id",
+						Unique:  true,
+						Indexer: &StringFieldIndex{Field: "ID"},
+					},
+					"name": &IndexSchema{
+						Name:         "name",
+						Unique:       true,
+						AllowMissing: true,
+						Indexer:      &StringFieldIndex{Field: "Name"},
+					},
+				},
+			},
+			"allocs": &TableSchema{
+				Name: "allocs",
+				Indexes: map[string]*IndexSchema{
+					"id": &IndexSchema{
+						Name:    "id",
+						Unique:  true,
+						Indexer: &StringFieldIndex{Field: "ID"},
+					},
+					"node": &IndexSchema{
+						Name:         "node",
+						AllowMissing: true,
+						Indexer:      &StringFieldIndex{Field: "NodeID"},
+					},
+				},
+				ForeignKeys: map[string]*ForeignKeySchema{
+					"node": &ForeignKeySchema{
+						Name:         "node",
+						Index:        "node",
+						Field:        "NodeID",
+						ForeignTable: "nodes",
+						ForeignIndex: "id",
+						OnDelete:     onDelete,
+					},
+					"node_name": &ForeignKeySchema{
+						Name:         "node_name",
+						Field:        "NodeName",
+						ForeignTable: "nodes",
+						ForeignIndex: "name",
+						OnDelete:     onDelete,
+					},
+				},
+			},
+		},
+	}
+}
+
+func testForeignKeyDB(t *testing.T, onDelete ForeignKeyAction) (*MemDB, *TestNode, *TestAlloc) {
+	db, err := NewMemDB(testForeignKeySchema(onDelete))
+	noErr(t, err)
+
+	node := &TestNode{ID: "node1", Name: "alpha"}
+	alloc := &TestAlloc{ID: "alloc1", NodeID: "node1"}
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("nodes", node))
+	noErr(t, txn.Insert("allocs", alloc))
+	txn.Commit()
+	return db, node, alloc
+}
+
+func TestForeignKeySchema_Validate(t *testing.T) {
+	cases := map[string]func(s *DBSchema){
+		"missing foreign table": func(s *DBSchema) {
+			s.Tables["allocs"].ForeignKeys["node"].ForeignTable = "nope"
+		},
+		"missing foreign index": func(s *DBSchema) {
+			s.Tables["allocs"].ForeignKeys["node"].ForeignIndex = "nope"
+		},
+		"non-unique foreign index": func(s *DBSchema) {
+			s.Tables["nodes"].Indexes["name"].Unique = false
+		},
+		"missing local index": func(s *DBSchema) {
+			s.Tables["allocs"].ForeignKeys["node"].Index = "nope"
+		},
+		"name mismatch": func(s *DBSchema) {
+			s.Tables["allocs"].ForeignKeys["node"].Name = "other"
+		},
+		"set-null without field": func(s *DBSchema) {
+			s.Tables["allocs"].ForeignKeys["node"].Field = ""
+		},
+		"set-null without allow missing": func(s *DBSchema) {
+			s.Tables["allocs"].Indexes["node"].AllowMissing = false
+		},
+		"set-null with integer field": func(s *DBSchema) {
+			s.Tables["nodes"].Indexes["name"].Indexer = &IntFieldIndex{Field: "Name"}
+		},
+		"set-null with boolean indexer": func(s *DBSchema) {
+			s.Tables["allocs"].ForeignKeys["node_name"].Indexer = &BoolFieldIndex{Field: "NodeName"}
+		},
+		"index and indexer": func(s *DBSchema) {
+			s.Tables["allocs"].ForeignKeys["node"].Indexer = &StringFieldIndex{Field: "NodeID"}
+		},
+		"underivable indexer": func(s *DBSchema) {
+			s.Tables["nodes"].Indexes["name"].Indexer = &CompoundIndex{
+				Indexes: []Indexer{&StringFieldIndex{Field: "Name"}},
+			}
+		},
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := testForeignKeySchema(SetNull)
+			mutate(s)
+			if err := s.Validate(); err == nil {
+				t.Fatalf("should not validate")
+			}
+		})
+	}
+
+	if err := testForeignKeySchema(SetNull).Validate(); err != nil {
+		t.Fatalf("should validate: %v", err)
+	}
+
+	// Zero values are only an issue for set-null
+	s := testForeignKeySchema(Restrict)
+	s.Tables["nodes"].Indexes["name"].Indexer = &IntFieldIndex{Field: "Name"}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("should validate: %v", err)
+	}
+}
+
+func TestTxn_ForeignKey_Insert(t *testing.T) {
+	db, _, _ := testForeignKeyDB(t, Restrict)
+	txn := db.Txn(true)
+	defer txn.Abort()
+
+	// A dangling reference is refused before any index is touched
+	err := txn.Insert("allocs", &TestAlloc{ID: "alloc2", NodeID: "nope"})
+	var fkErr *ForeignKeyError
+	if !errors.As(err, &fkErr) || fkErr.Table != "allocs" || fkErr.ForeignKey != "node" {
+		t.Fatalf("bad: %v", err)
+	}
+	if raw, _ := txn.First("allocs", "id", "alloc2"); raw != nil {
+		t.Fatalf("should not exist: %#v", raw)
+	}
+
+	// References derived from a field are enforced too
+	err = txn.Insert("allocs", &TestAlloc{ID: "alloc2", NodeName: "beta"})
+	if !errors.As(err, &fkErr) || fkErr.ForeignKey != "node_name" {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// Missing references are allowed, and parents inserted in the same
+	// transaction can be referenced
+	noErr(t, txn.Insert("allocs", &TestAlloc{ID: "alloc3"}))
+	noErr(t, txn.Insert("nodes", &TestNode{ID: "node2", Name: "beta"}))
+	noErr(t, txn.Insert("allocs", &TestAlloc{ID: "alloc2", NodeID: "node2", NodeName: "beta"}))
+}
+
+func TestTxn_ForeignKey_UpdateReferenced(t *testing.T) {
+	db, node, _ := testForeignKeyDB(t, Cascade)
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("allocs", &TestAlloc{ID: "alloc2", NodeName: "alpha"}))
+	txn.Commit()
+
+	// Renaming the node would orphan alloc2
+	txn = db.Txn(true)
+	defer txn.Abort()
+	err := txn.Insert("nodes", &TestNode{ID: node.ID, Name: "renamed"})
+	var fkErr *ForeignKeyError
+	if !errors.As(err, &fkErr) || fkErr.ForeignKey != "node_name" {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// Updates that keep the referenced values are fine
+	noErr(t, txn.Insert("nodes", &TestNode{ID: node.ID, Name: node.Name}))
+}
+
+func TestTxn_ForeignKey_Restrict(t *testing.T) {
+	db, node, alloc := testForeignKeyDB(t, Restrict)
+
+	txn := db.Txn(true)
+	err := txn.Delete("nodes", node)
+	var fkErr *ForeignKeyError
+	if !errors.As(err, &fkErr) {
+		t.Fatalf("bad: %v", err)
+	}
+	if raw, _ := txn.First("nodes", "id", node.ID); raw != node {
+		t.Fatalf("node should still exist")
+	}
+	if _, err := txn.DeletePrefix("nodes", "id_prefix", "node"); err == nil {
+		t.Fatalf("expected error")
+	}
+	txn.Abort()
+
+	// Once the reference is gone the delete succeeds
+	txn = db.Txn(true)
+	noErr(t, txn.Delete("allocs", alloc))
+	noErr(t, txn.Delete("nodes", node))
+	txn.Commit()
+}
+
+func TestTxn_ForeignKey_Cascade(t *testing.T) {
+	db, node, alloc := testForeignKeyDB(t, Cascade)
+
+	txn := db.Txn(true)
+	txn.TrackChanges()
+	noErr(t, txn.Delete("nodes", node))
+	if raw, _ := txn.First("allocs", "id", alloc.ID); raw != nil {
+		t.Fatalf("alloc should be deleted: %#v", raw)
+	}
+
+	changes := txn.Changes()
+	if len(changes) != 2 {
+		t.Fatalf("bad: %#v", changes)
+	}
+	if changes[0].Table != "nodes" || !changes[0].Deleted() {
+		t.Fatalf("bad: %#v", changes[0])
+	}
+	if changes[1].Table != "allocs" || changes[1].Before != alloc || !changes[1].Deleted() {
+		t.Fatalf("bad: %#v", changes[1])
+	}
+	txn.Commit()
+}
+
+func TestTxn_ForeignKey_CascadePrefix(t *testing.T) {
+	db, node, alloc := testForeignKeyDB(t, Cascade)
+
+	txn := db.Txn(true)
+	deleted, err := txn.DeletePrefix("nodes", "id_prefix", "node")
+	noErr(t, err)
+	if !deleted {
+		t.Fatalf("expected delete")
+	}
+	if raw, _ := txn.First("nodes", "id", node.ID); raw != nil {
+		t.Fatalf("node should be deleted: %#v", raw)
+	}
+	if raw, _ := txn.First("allocs", "id", alloc.ID); raw != nil {
+		t.Fatalf("alloc should be deleted: %#v", raw)
+	}
+	txn.Commit()
+}
+
+func TestTxn_ForeignKey_SetNull(t *testing.T) {
+	db, node, alloc := testForeignKeyDB(t, SetNull)
+
+	txn := db.Txn(true)
+	txn.TrackChanges()
+	noErr(t, txn.Delete("nodes", node))
+
+	raw, err := txn.First("allocs", "id", alloc.ID)
+	noErr(t, err)
+	updated := raw.(*TestAlloc)
+	if updated == alloc || updated.NodeID != "" {
+		t.Fatalf("bad: %#v", updated)
+	}
+	if alloc.NodeID != "node1" {
+		t.Fatalf("original object should not be modified")
+	}
+	if raw, _ := txn.First("allocs", "node", "node1"); raw != nil {
+		t.Fatalf("should not be indexed by node: %#v", raw)
+	}
+
+	changes := txn.Changes()
+	if len(changes) != 2 || !changes[1].Updated() || changes[1].After != updated {
+		t.Fatalf("bad: %#v", changes)
+	}
+	txn.Commit()
+}
+
+func TestTxn_ForeignKey_CascadeRestricted(t *testing.T) {
+	// Claims restrict the delete of the allocs cascaded from their node
+	schema := testForeignKeySchema(Cascade)
+	schema.Tables["claims"] = &TableSchema{
+		Name: "claims",
+		Indexes: map[string]*IndexSchema{
+			"id": &IndexSchema{
+				Name:    "id",
+				Unique:  true,
+				Indexer: &StringFieldIndex{Field: "ID"},
+			},
+		},
+		ForeignKeys: map[string]*ForeignKeySchema{
+			"alloc": &ForeignKeySchema{
+				Name:         "alloc",
+				Field:        "NodeID",
+				ForeignTable: "allocs",
+				ForeignIndex: "id",
+				OnDelete:     Restrict,
+			},
+		},
+	}
+	db, err := NewMemDB(schema)
+	noErr(t, err)
+	node := &TestNode{ID: "node1", Name: "alpha"}
+	alloc := &TestAlloc{ID: "alloc1", NodeID: "node1"}
+	claim := &TestAlloc{ID: "claim1", NodeID: "alloc1"}
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("nodes", node))
+	noErr(t, txn.Insert("allocs", alloc))
+	noErr(t, txn.Insert("claims", claim))
+	txn.Commit()
+
+	// The failed delete leaves the transaction unchanged
+	txn = db.Txn(true)
+	txn.TrackChanges()
+	var fkErr *ForeignKeyError
+	if err := txn.Delete("nodes", node); !errors.As(err, &fkErr) || fkErr.Table != "claims" {
+		t.Fatalf("bad: %v", err)
+	}
+	if raw, _ := txn.First("nodes", "id", node.ID); raw != node {
+		t.Fatalf("node should still exist")
+	}
+	if raw, _ := txn.First("allocs", "id", alloc.ID); raw != alloc {
+		t.Fatalf("alloc should still exist")
+	}
+	if changes := txn.Changes(); len(changes) != 0 {
+		t.Fatalf("bad: %#v", changes)
+	}
+	txn.Commit()
+
+	// Once the claim is gone the delete cascades
+	txn = db.Txn(true)
+	noErr(t, txn.Delete("claims", claim))
+	noErr(t, txn.Delete("nodes", node))
+	if raw, _ := txn.First("allocs", "id", alloc.ID); raw != nil {
+		t.Fatalf("alloc should be deleted: %#v", raw)
+	}
+	txn.Commit()
+}
@@ -0,0 +1,161 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+var (
+	// ErrIndexNotRetained is returned by TxnAt when the state of the
+	// database at the requested commit index is no longer retained.
+	ErrIndexNotRetained = fmt.Errorf("commit index not retained")
+)
+
+// WithHistory makes the database retain the roots of the last n commits,
+// so that they can be read with TxnAt. Since the roots share most of their
+// structure, the cost of a retained root is proportional to the size of the
+// changes made by its commit, not to the size of the database. A limit of
+// zero or less retains every root, unless WithHistoryWindow is also given.
+func WithHistory(n int) Option {
+	return func(db *MemDB) {
+		if db.history == nil {
+			db.history = new(history)
+		}
+		db.history.limit = n
+	}
+}
+
+// WithHistoryWindow makes the database retain the roots of the commits made
+// within the given duration, so that they can be read with TxnAt. It can be
+// combined with WithHistory, in which case a root is dropped as soon as
+// either limit is exceeded.
+func WithHistoryWindow(window time.Duration) Option {
+	return func(db *MemDB) {
+		if db.history == nil {
+			db.history = new(history)
+		}
+		db.history.window = window
+	}
+}
+
+// historyEntry is a retained root along with the commit index that
+// produced it.
+type historyEntry struct {
+	index uint64
+	root  *iradix.Tree
+	time  time.Time
+}
+
+// history holds the retained roots of a database, ordered by commit index.
+type history struct {
+	limit  int
+	window time.Duration
+
+	l       sync.Mutex
+	entries []historyEntry
+}
+
+// add retains a new root and drops the roots that are no longer within the
+// limits.
+func (h *history) add(index uint64, root *iradix.Tree, now time.Time) {
+	h.l.Lock()
+	defer h.l.Unlock()
+
+	h.entries = append(h.entries, historyEntry{index: index, root: root, time: now})
+	h.prune(now)
+}
+
+// prune drops the roots that are no longer within the limits. The lock must
+// be held.
+func (h *history) prune(now time.Time) {
+	drop := 0
+	if h.limit > 0 && len(h.entries) > h.limit {
+		drop = len(h.entries) - h.limit
+	}
+	if h.window > 0 {
+		for drop < len(h.entries) && now.Sub(h.entries[drop].time) > h.window {
+			drop++
+		}
+	}
+
+	// The latest root is always kept, it is the current state
+	if drop >= len(h.entries) {
+		drop = len(h.entries) - 1
+	}
+	if drop <= 0 {
+		return
+	}
+
+	// Clear the dropped entries so their roots can be garbage collected
+	for i := 0; i < drop; i++ {
+		h.entries[i] = historyEntry{}
+	}
+	h.entries = h.entries[drop:]
+}
+
+// snapshot returns an empty history with the same limits, seeded with the
+// given root.
+func (h *history) snapshot(index uint64, root *iradix.Tree) *history {
+	clone := &history{limit: h.limit, window: h.window}
+	clone.add(index, root, time.Now())
+	return clone
+}
+
+// get returns the retained root that was current at the given commit index.
+func (h *history) get(index uint64, now time.Time) (*iradix.Tree, bool) {
+	h.l.Lock()
+	defer h.l.Unlock()
+
+	h.prune(now)
+
+	// Find the last root committed at or before the index. An index older
+	// than the first retained root isn't known to have the same state.
+	i := sort.Search(len(h.entries), func(i int) bool {
+		return h.entries[i].index > index
+	})
+	if i == 0 {
+		return nil, false
+	}
+	return h.entries[i-1].root, true
+}
+
+// TxnAt starts a read transaction against the state of the database at the
+// given commit index, as returned by Txn.CommitIndex. The state is the one
+// left by the last transaction committed at or before that index.
+//
+// The current state is always available. Older states are only available if
+// the database retains its history, see WithHistory and WithHistoryWindow,
+// and ErrIndexNotRetained is returned otherwise.
+func (db *MemDB) TxnAt(index uint64) (*Txn, error) {
+	root := db.getRoot()
+	current := rootCommitIndex(root)
+	if index > current {
+		return nil, fmt.Errorf("commit index %d is ahead of the database at %d", index, current)
+	}
+	if index == current {
+		return db.readTxn(root), nil
+	}
+
+	if db.history != nil {
+		if root, ok := db.history.get(index, time.Now()); ok {
+			return db.readTxn(root), nil
+		}
+	}
+	return nil, ErrIndexNotRetained
+}
+
+// rootCommitIndex returns the commit index stored in a root.
+func rootCommitIndex(root *iradix.Tree) uint64 {
+	raw, ok := root.Get(commitIndexPath)
+	if !ok {
+		return 0
+	}
+	return raw.(uint64)
+}
@@ -0,0 +1,158 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testHistoryDB(t *testing.T, opts ...Option) *MemDB {
+	t.Helper()
+	db, err := NewMemDB(testValidSchema(), opts...)
+	noErr(t, err)
+	return db
+}
+
+func testHistoryCommit(t *testing.T, db *MemDB, obj *TestObject) uint64 {
+	t.Helper()
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", obj))
+	txn.Commit()
+	return db.Txn(false).CommitIndex()
+}
+
+func TestMemDB_TxnAt(t *testing.T) {
+	db := testHistoryDB(t, WithHistory(10))
+
+	i1 := testHistoryCommit(t, db, testSavepointObj("a", "x"))
+	i2 := testHistoryCommit(t, db, testSavepointObj("b", "x"))
+
+	// A commit that changes nothing doesn't get an index
+	txn := db.Txn(true)
+	txn.Commit()
+
+	i3 := testHistoryCommit(t, db, testSavepointObj("a", "y"))
+
+	txn, err := db.TxnAt(0)
+	noErr(t, err)
+	assertExists(t, txn, map[string]bool{"a": false, "b": false})
+
+	txn, err = db.TxnAt(i1)
+	noErr(t, err)
+	assertExists(t, txn, map[string]bool{"a": true, "b": false})
+	if idx := txn.CommitIndex(); idx != i1 {
+		t.Fatalf("bad: %d", idx)
+	}
+
+	txn, err = db.TxnAt(i2)
+	noErr(t, err)
+	assertExists(t, txn, map[string]bool{"a": true, "b": true})
+	raw, err := txn.First("main", "id", "a")
+	noErr(t, err)
+	if raw.(*TestObject).Foo != "x" {
+		t.Fatalf("bad: %#v", raw)
+	}
+
+	txn, err = db.TxnAt(i3)
+	noErr(t, err)
+	raw, err = txn.First("main", "foo", "y")
+	noErr(t, err)
+	if raw == nil {
+		t.Fatalf("expected object")
+	}
+
+	if _, err := db.TxnAt(i3 + 1); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	// The transactions are read-only
+	txn, err = db.TxnAt(i1)
+	noErr(t, err)
+	if err := txn.Insert("main", testSavepointObj("c", "x")); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestMemDB_TxnAt_Limit(t *testing.T) {
+	db := testHistoryDB(t, WithHistory(2))
+
+	i1 := testHistoryCommit(t, db, testSavepointObj("a", "x"))
+	i2 := testHistoryCommit(t, db, testSavepointObj("b", "x"))
+	i3 := testHistoryCommit(t, db, testSavepointObj("c", "x"))
+
+	for _, idx := range []uint64{0, i1} {
+		if _, err := db.TxnAt(idx); !errors.Is(err, ErrIndexNotRetained) {
+			t.Fatalf("index %d: bad: %v", idx, err)
+		}
+	}
+	for _, idx := range []uint64{i2, i3} {
+		if _, err := db.TxnAt(idx); err != nil {
+			t.Fatalf("index %d: bad: %v", idx, err)
+		}
+	}
+}
+
+func TestMemDB_TxnAt_Window(t *testing.T) {
+	db := testHistoryDB(t, WithHistoryWindow(50*time.Millisecond))
+
+	i1 := testHistoryCommit(t, db, testSavepointObj("a", "x"))
+	if _, err := db.TxnAt(0); err != nil {
+		t.Fatalf("bad: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	i2 := testHistoryCommit(t, db, testSavepointObj("b", "x"))
+	if _, err := db.TxnAt(0); !errors.Is(err, ErrIndexNotRetained) {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// The current state is kept even once it is outside the window
+	time.Sleep(100 * time.Millisecond)
+	if _, err := db.TxnAt(i1); !errors.Is(err, ErrIndexNotRetained) {
+		t.Fatalf("bad: %v", err)
+	}
+	txn, err := db.TxnAt(i2)
+	noErr(t, err)
+	assertExists(t, txn, map[string]bool{"a": true, "b": true})
+}
+
+func TestMemDB_TxnAt_NoHistory(t *testing.T) {
+	db := testDB(t)
+
+	i1 := testHistoryCommit(t, db, testSavepointObj("a", "x"))
+	txn, err := db.TxnAt(i1)
+	noErr(t, err)
+	assertExists(t, txn, map[string]bool{"a": true})
+
+	testHistoryCommit(t, db, testSavepointObj("b", "x"))
+	if _, err := db.TxnAt(i1); !errors.Is(err, ErrIndexNotRetained) {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestMemDB_TxnAt_Snapshot(t *testing.T) {
+	db := testHistoryDB(t, WithHistory(10))
+	i1 := testHistoryCommit(t, db, testSavepointObj("a", "x"))
+
+	snap := db.Snapshot()
+	i2 := testHistoryCommit(t, snap, testSavepointObj("b", "x"))
+	testHistoryCommit(t, db, testSavepointObj("c", "x"))
+
+	// The snapshot only retains its own history
+	if _, err := snap.TxnAt(0); !errors.Is(err, ErrIndexNotRetained) {
+		t.Fatalf("bad: %v", err)
+	}
+	txn, err := snap.TxnAt(i1)
+	noErr(t, err)
+	assertExists(t, txn, map[string]bool{"a": true, "b": false, "c": false})
+	txn, err = snap.TxnAt(i2)
+	noErr(t, err)
+	assertExists(t, txn, map[string]bool{"a": true, "b": true, "c": false})
+
+	txn, err = db.TxnAt(i1)
+	noErr(t, err)
+	assertExists(t, txn, map[string]bool{"a": true, "b": false})
+}
@@ -11,6 +11,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // Indexer is an interface used for defining indexes. Indexes are used
@@ -67,7 +68,7 @@ func (s *StringFieldIndex) FromObject(obj interface{}) (bool, []byte, error) {
 	v := reflect.ValueOf(obj)
 	v = reflect.Indirect(v) // Dereference the pointer if any
 
-	fv := v.FieldByName(s.Field)
+	fv := fieldByName(v, s.Field)
 	isPtr := fv.Kind() == reflect.Ptr
 	fv = reflect.Indirect(fv)
 	if !isPtr && !fv.IsValid() {
@@ -136,7 +137,7 @@ func (s *StringSliceFieldIndex) FromObject(obj interface{}) (bool, [][]byte, err
 	v := reflect.ValueOf(obj)
 	v = reflect.Indirect(v) // Dereference the pointer if any
 
-	fv := v.FieldByName(s.Field)
+	fv := fieldByName(v, s.Field)
 	if !fv.IsValid() {
 		return false, nil,
 			fmt.Errorf("field '%s' for %#v is invalid", s.Field, obj)
@@ -221,7 +222,7 @@ func (s *StringMapFieldIndex) FromObject(obj interface{}) (bool, [][]byte, error
 	v := reflect.ValueOf(obj)
 	v = reflect.Indirect(v) // Dereference the pointer if any
 
-	fv := v.FieldByName(s.Field)
+	fv := fieldByName(v, s.Field)
 	if !fv.IsValid() {
 		return false, nil, fmt.Errorf("field '%s' for %#v is invalid", s.Field, obj)
 	}
@@ -296,7 +297,7 @@ func (i *IntFieldIndex) FromObject(obj interface{}) (bool, []byte, error) {
 	v := reflect.ValueOf(obj)
 	v = reflect.Indirect(v) // Dereference the pointer if any
 
-	fv := v.FieldByName(i.Field)
+	fv := fieldByName(v, i.Field)
 	if !fv.IsValid() {
 		return false, nil,
 			fmt.Errorf("field '%s' for %#v is invalid", i.Field, obj)
@@ -338,6 +339,13 @@ func (i *IntFieldIndex) FromArgs(args ...interface{}) ([]byte, error) {
 	return buf, nil
 }
 
+// EncodeInt encodes a signed integer of the given size in bytes the same way
+// IntFieldIndex does, so that the keys sort in numeric order. It is used by
+// the indexers generated by indexer-gen.
+func EncodeInt(val int64, size int) []byte {
+	return encodeInt(val, size)
+}
+
 func encodeInt(val int64, size int) []byte {
 	buf := make([]byte, size)
 
@@ -392,7 +400,7 @@ func (u *UintFieldIndex) FromObject(obj interface{}) (bool, []byte, error) {
 	v := reflect.ValueOf(obj)
 	v = reflect.Indirect(v) // Dereference the pointer if any
 
-	fv := v.FieldByName(u.Field)
+	fv := fieldByName(v, u.Field)
 	if !fv.IsValid() {
 		return false, nil,
 			fmt.Errorf("field '%s' for %#v is invalid", u.Field, obj)
@@ -434,6 +442,13 @@ func (u *UintFieldIndex) FromArgs(args ...interface{}) ([]byte, error) {
 	return buf, nil
 }
 
+// EncodeUint encodes an unsigned integer of the given size in bytes the same
+// way UintFieldIndex does. It is used by the indexers generated by
+// indexer-gen.
+func EncodeUint(val uint64, size int) []byte {
+	return encodeUInt(val, size)
+}
+
 func encodeUInt(val uint64, size int) []byte {
 	buf := make([]byte, size)
 
@@ -482,7 +497,7 @@ func (i *BoolFieldIndex) FromObject(obj interface{}) (bool, []byte, error) {
 	v := reflect.ValueOf(obj)
 	v = reflect.Indirect(v) // Dereference the pointer if any
 
-	fv := v.FieldByName(i.Field)
+	fv := fieldByName(v, i.Field)
 	if !fv.IsValid() {
 		return false, nil,
 			fmt.Errorf("field '%s' for %#v is invalid", i.Field, obj)
@@ -519,7 +534,7 @@ func (u *UUIDFieldIndex) FromObject(obj interface{}) (bool, []byte, error) {
 	v := reflect.ValueOf(obj)
 	v = reflect.Indirect(v) // Dereference the pointer if any
 
-	fv := v.FieldByName(u.Field)
+	fv := fieldByName(v, u.Field)
 	if !fv.IsValid() {
 		return false, nil,
 			fmt.Errorf("field '%s' for %#v is invalid", u.Field, obj)
@@ -610,7 +625,7 @@ func (f *FieldSetIndex) FromObject(obj interface{}) (bool, []byte, error) {
 	v := reflect.ValueOf(obj)
 	v = reflect.Indirect(v) // Dereference the pointer if any
 
-	fv := v.FieldByName(f.Field)
+	fv := fieldByName(v, f.Field)
 	if !fv.IsValid() {
 		return false, nil,
 			fmt.Errorf("field '%s' for %#v is invalid", f.Field, obj)
@@ -932,3 +947,55 @@ func (c *CompoundMultiIndex) FromArgs(args ...interface{}) ([]byte, error) {
 	}
 	return out, nil
 }
+
+// fieldKey identifies a field looked up by name in a struct type.
+type fieldKey struct {
+	typ  reflect.Type
+	name string
+}
+
+// fieldIndexes caches the index sequence of the fields looked up by
+// fieldByName, keyed by fieldKey. A nil sequence means there is no such
+// field.
+var fieldIndexes sync.Map
+
+// fieldByName returns the field of a struct with the given name, like
+// reflect.Value.FieldByName, but only resolves the name once per type.
+func fieldByName(v reflect.Value, name string) reflect.Value {
+	if v.Kind() != reflect.Struct {
+		return v.FieldByName(name)
+	}
+
+	key := fieldKey{typ: v.Type(), name: name}
+	raw, ok := fieldIndexes.Load(key)
+	if !ok {
+		var index []int
+		if field, ok := v.Type().FieldByName(name); ok {
+			index = field.Index
+		}
+		raw, _ = fieldIndexes.LoadOrStore(key, index)
+	}
+
+	index := raw.([]int)
+	if index == nil {
+		return reflect.Value{}
+	}
+	if len(index) == 1 {
+		return v.Field(index[0])
+	}
+	return v.FieldByIndex(index)
+}
+
+// indexValues extracts the index values of an object using either a
+// SingleIndexer or a MultiIndexer.
+func indexValues(indexer Indexer, obj interface{}) (bool, [][]byte, error) {
+	switch indexer := indexer.(type) {
+	case SingleIndexer:
+		ok, val, err := indexer.FromObject(obj)
+		return ok, [][]byte{val}, err
+	case MultiIndexer:
+		return indexer.FromObject(obj)
+	default:
+		return false, nil, fmt.Errorf("indexer must be a SingleIndexer or MultiIndexer")
+	}
+}
@@ -594,6 +594,65 @@ func BenchmarkUUIDFieldIndex_parseString(b *testing.B) {
 	}
 }
 
+type testEmbedded struct {
+	*TestObject
+	Extra string
+}
+
+func TestFieldByName(t *testing.T) {
+	obj := testObj()
+	v := reflect.ValueOf(*obj)
+
+	// Repeated lookups hit the cache
+	for i := 0; i < 2; i++ {
+		if fv := fieldByName(v, "Foo"); fv.String() != "Testing" {
+			t.Fatalf("bad: %v", fv)
+		}
+		if fv := fieldByName(v, "Nope"); fv.IsValid() {
+			t.Fatalf("bad: %v", fv)
+		}
+	}
+
+	// Promoted fields of embedded structs are found too
+	e := reflect.ValueOf(testEmbedded{TestObject: obj, Extra: "x"})
+	if fv := fieldByName(e, "Foo"); fv.String() != "Testing" {
+		t.Fatalf("bad: %v", fv)
+	}
+	if fv := fieldByName(e, "Extra"); fv.String() != "x" {
+		t.Fatalf("bad: %v", fv)
+	}
+
+	indexer := &StringFieldIndex{Field: "Foo"}
+	ok, val, err := indexer.FromObject(&testEmbedded{TestObject: obj})
+	if err != nil || !ok || string(val) != "Testing\x00" {
+		t.Fatalf("bad: %v %q %v", ok, val, err)
+	}
+}
+
+func BenchmarkFieldByName(b *testing.B) {
+	v := reflect.ValueOf(*testObj())
+	b.Run("reflect", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			v.FieldByName("Uint64")
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fieldByName(v, "Uint64")
+		}
+	})
+}
+
+func BenchmarkStringFieldIndex_FromObject(b *testing.B) {
+	obj := testObj()
+	indexer := &StringFieldIndex{Field: "Baz"}
+	for i := 0; i < b.N; i++ {
+		if _, _, err := indexer.FromObject(obj); err != nil {
+			b.Fatalf("err: %v", err)
+		}
+	}
+}
+
 func generateUUID() ([]byte, string) {
 	buf := make([]byte, 16)
 	if _, err := crand.Read(buf); err != nil {
@@ -0,0 +1,27 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package example shows the indexers generated by indexer-gen, and checks
+// that they produce the same keys as the reflection-based indexers.
+package example
+
+//go:generate go run .. -type Object
+
+// Status is a string type indexed like a string.
+type Status string
+
+// Object is indexed by the generated indexers in object_indexers.go.
+type Object struct {
+	ID     string
+	Name   *string
+	Tags   []string
+	Status Status
+	Count  int
+	Small  int8
+	Size   uint32
+	Port   uint
+	Active bool
+
+	// Fields of unsupported types are skipped
+	Meta map[string]string
+}
@@ -0,0 +1,272 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Code generated by indexer-gen. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// indexedObject returns the Object an object passed to an indexer refers to.
+func indexedObject(obj interface{}) (*Object, error) {
+	switch o := obj.(type) {
+	case *Object:
+		if o == nil {
+			return nil, fmt.Errorf("object is a nil *Object")
+		}
+		return o, nil
+	case Object:
+		return &o, nil
+	default:
+		return nil, fmt.Errorf("object %#v is not of type Object", obj)
+	}
+}
+
+// ObjectIDIndex indexes the ID field of Object without reflection. It
+// produces the same keys as a memdb.StringFieldIndex on the same field.
+type ObjectIDIndex struct {
+	Lowercase bool
+}
+
+var _ memdb.SingleIndexer = (*ObjectIDIndex)(nil)
+var _ memdb.PrefixIndexer = (*ObjectIDIndex)(nil)
+
+func (i *ObjectIDIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	o, err := indexedObject(obj)
+	if err != nil {
+		return false, nil, err
+	}
+
+	val := string(o.ID)
+	if val == "" {
+		return false, nil, nil
+	}
+	if i.Lowercase {
+		val = strings.ToLower(val)
+	}
+
+	// Add the null character as a terminator
+	return true, []byte(val + "\x00"), nil
+}
+
+func (i *ObjectIDIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.StringFieldIndex{Lowercase: i.Lowercase}).FromArgs(args...)
+}
+
+func (i *ObjectIDIndex) PrefixFromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.StringFieldIndex{Lowercase: i.Lowercase}).PrefixFromArgs(args...)
+}
+
+// ObjectNameIndex indexes the Name field of Object without reflection. It
+// produces the same keys as a memdb.StringFieldIndex on the same field.
+type ObjectNameIndex struct {
+	Lowercase bool
+}
+
+var _ memdb.SingleIndexer = (*ObjectNameIndex)(nil)
+var _ memdb.PrefixIndexer = (*ObjectNameIndex)(nil)
+
+func (i *ObjectNameIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	o, err := indexedObject(obj)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if o.Name == nil {
+		return false, []byte(""), nil
+	}
+	val := string(*o.Name)
+	if val == "" {
+		return false, nil, nil
+	}
+	if i.Lowercase {
+		val = strings.ToLower(val)
+	}
+
+	// Add the null character as a terminator
+	return true, []byte(val + "\x00"), nil
+}
+
+func (i *ObjectNameIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.StringFieldIndex{Lowercase: i.Lowercase}).FromArgs(args...)
+}
+
+func (i *ObjectNameIndex) PrefixFromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.StringFieldIndex{Lowercase: i.Lowercase}).PrefixFromArgs(args...)
+}
+
+// ObjectTagsIndex indexes the Tags field of Object without reflection. It
+// produces the same keys as a memdb.StringSliceFieldIndex on the same field.
+type ObjectTagsIndex struct {
+	Lowercase bool
+}
+
+var _ memdb.MultiIndexer = (*ObjectTagsIndex)(nil)
+var _ memdb.PrefixIndexer = (*ObjectTagsIndex)(nil)
+
+func (i *ObjectTagsIndex) FromObject(obj interface{}) (bool, [][]byte, error) {
+	o, err := indexedObject(obj)
+	if err != nil {
+		return false, nil, err
+	}
+
+	vals := make([][]byte, 0, len(o.Tags))
+	for _, v := range o.Tags {
+		val := string(v)
+		if val == "" {
+			continue
+		}
+		if i.Lowercase {
+			val = strings.ToLower(val)
+		}
+
+		// Add the null character as a terminator
+		vals = append(vals, []byte(val+"\x00"))
+	}
+	if len(vals) == 0 {
+		return false, nil, nil
+	}
+	return true, vals, nil
+}
+
+func (i *ObjectTagsIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.StringSliceFieldIndex{Lowercase: i.Lowercase}).FromArgs(args...)
+}
+
+func (i *ObjectTagsIndex) PrefixFromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.StringSliceFieldIndex{Lowercase: i.Lowercase}).PrefixFromArgs(args...)
+}
+
+// ObjectStatusIndex indexes the Status field of Object without reflection. It
+// produces the same keys as a memdb.StringFieldIndex on the same field.
+type ObjectStatusIndex struct {
+	Lowercase bool
+}
+
+var _ memdb.SingleIndexer = (*ObjectStatusIndex)(nil)
+var _ memdb.PrefixIndexer = (*ObjectStatusIndex)(nil)
+
+func (i *ObjectStatusIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	o, err := indexedObject(obj)
+	if err != nil {
+		return false, nil, err
+	}
+
+	val := string(o.Status)
+	if val == "" {
+		return false, nil, nil
+	}
+	if i.Lowercase {
+		val = strings.ToLower(val)
+	}
+
+	// Add the null character as a terminator
+	return true, []byte(val + "\x00"), nil
+}
+
+func (i *ObjectStatusIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.StringFieldIndex{Lowercase: i.Lowercase}).FromArgs(args...)
+}
+
+func (i *ObjectStatusIndex) PrefixFromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.StringFieldIndex{Lowercase: i.Lowercase}).PrefixFromArgs(args...)
+}
+
+// ObjectCountIndex indexes the Count field of Object without reflection. It
+// produces the same keys as a memdb.IntFieldIndex on the same field.
+type ObjectCountIndex struct{}
+
+var _ memdb.SingleIndexer = (*ObjectCountIndex)(nil)
+
+func (i *ObjectCountIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	o, err := indexedObject(obj)
+	if err != nil {
+		return false, nil, err
+	}
+	return true, memdb.EncodeInt(int64(o.Count), strconv.IntSize/8), nil
+}
+
+func (i *ObjectCountIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.IntFieldIndex{}).FromArgs(args...)
+}
+
+// ObjectSmallIndex indexes the Small field of Object without reflection. It
+// produces the same keys as a memdb.IntFieldIndex on the same field.
+type ObjectSmallIndex struct{}
+
+var _ memdb.SingleIndexer = (*ObjectSmallIndex)(nil)
+
+func (i *ObjectSmallIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	o, err := indexedObject(obj)
+	if err != nil {
+		return false, nil, err
+	}
+	return true, memdb.EncodeInt(int64(o.Small), 1), nil
+}
+
+func (i *ObjectSmallIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.IntFieldIndex{}).FromArgs(args...)
+}
+
+// ObjectSizeIndex indexes the Size field of Object without reflection. It
+// produces the same keys as a memdb.UintFieldIndex on the same field.
+type ObjectSizeIndex struct{}
+
+var _ memdb.SingleIndexer = (*ObjectSizeIndex)(nil)
+
+func (i *ObjectSizeIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	o, err := indexedObject(obj)
+	if err != nil {
+		return false, nil, err
+	}
+	return true, memdb.EncodeUint(uint64(o.Size), 4), nil
+}
+
+func (i *ObjectSizeIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.UintFieldIndex{}).FromArgs(args...)
+}
+
+// ObjectPortIndex indexes the Port field of Object without reflection. It
+// produces the same keys as a memdb.UintFieldIndex on the same field.
+type ObjectPortIndex struct{}
+
+var _ memdb.SingleIndexer = (*ObjectPortIndex)(nil)
+
+func (i *ObjectPortIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	o, err := indexedObject(obj)
+	if err != nil {
+		return false, nil, err
+	}
+	return true, memdb.EncodeUint(uint64(o.Port), strconv.IntSize/8), nil
+}
+
+func (i *ObjectPortIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.UintFieldIndex{}).FromArgs(args...)
+}
+
+// ObjectActiveIndex indexes the Active field of Object without reflection. It
+// produces the same keys as a memdb.BoolFieldIndex on the same field.
+type ObjectActiveIndex struct{}
+
+var _ memdb.SingleIndexer = (*ObjectActiveIndex)(nil)
+
+func (i *ObjectActiveIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	o, err := indexedObject(obj)
+	if err != nil {
+		return false, nil, err
+	}
+	if o.Active {
+		return true, []byte{1}, nil
+	}
+	return true, []byte{0}, nil
+}
+
+func (i *ObjectActiveIndex) FromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.BoolFieldIndex{}).FromArgs(args...)
+}
@@ -0,0 +1,109 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package example
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-memdb"
+)
+
+func testObjects() []interface{} {
+	name, empty := "Alpha", ""
+	return []interface{}{
+		&Object{},
+		&Object{
+			ID:     "abc",
+			Name:   &name,
+			Tags:   []string{"Foo", "", "bar"},
+			Status: "Running",
+			Count:  -42,
+			Small:  -128,
+			Size:   1 << 31,
+			Port:   8500,
+			Active: true,
+		},
+		Object{ID: "ABC", Name: &empty, Tags: []string{""}, Count: 1 << 40, Small: 127},
+	}
+}
+
+func TestGeneratedIndexers(t *testing.T) {
+	cases := map[string]struct {
+		generated memdb.Indexer
+		builtin   memdb.Indexer
+		args      []interface{}
+	}{
+		"string":       {&ObjectIDIndex{}, &memdb.StringFieldIndex{Field: "ID"}, []interface{}{"abc"}},
+		"lowercase":    {&ObjectIDIndex{Lowercase: true}, &memdb.StringFieldIndex{Field: "ID", Lowercase: true}, []interface{}{"ABC"}},
+		"string ptr":   {&ObjectNameIndex{}, &memdb.StringFieldIndex{Field: "Name"}, []interface{}{"Alpha"}},
+		"string slice": {&ObjectTagsIndex{Lowercase: true}, &memdb.StringSliceFieldIndex{Field: "Tags", Lowercase: true}, []interface{}{"Foo"}},
+		"named string": {&ObjectStatusIndex{}, &memdb.StringFieldIndex{Field: "Status"}, []interface{}{"Running"}},
+		"int":          {&ObjectCountIndex{}, &memdb.IntFieldIndex{Field: "Count"}, []interface{}{-42}},
+		"int8":         {&ObjectSmallIndex{}, &memdb.IntFieldIndex{Field: "Small"}, []interface{}{int8(-128)}},
+		"uint32":       {&ObjectSizeIndex{}, &memdb.UintFieldIndex{Field: "Size"}, []interface{}{uint32(7)}},
+		"uint":         {&ObjectPortIndex{}, &memdb.UintFieldIndex{Field: "Port"}, []interface{}{uint(8500)}},
+		"bool":         {&ObjectActiveIndex{}, &memdb.BoolFieldIndex{Field: "Active"}, []interface{}{true}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			for _, obj := range testObjects() {
+				var got, want []interface{}
+				switch generated := c.generated.(type) {
+				case memdb.SingleIndexer:
+					ok, val, err := generated.FromObject(obj)
+					got = []interface{}{ok, val, err}
+					ok, val, err = c.builtin.(memdb.SingleIndexer).FromObject(obj)
+					want = []interface{}{ok, val, err}
+				case memdb.MultiIndexer:
+					ok, vals, err := generated.FromObject(obj)
+					got = []interface{}{ok, vals, err}
+					ok, vals, err = c.builtin.(memdb.MultiIndexer).FromObject(obj)
+					want = []interface{}{ok, vals, err}
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("FromObject(%#v): got %v, want %v", obj, got, want)
+				}
+			}
+
+			got, err := c.generated.FromArgs(c.args...)
+			if err != nil {
+				t.Fatalf("err: %v", err)
+			}
+			want, _ := c.builtin.FromArgs(c.args...)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("FromArgs: got %v, want %v", got, want)
+			}
+
+			if prefix, ok := c.generated.(memdb.PrefixIndexer); ok {
+				got, _ := prefix.PrefixFromArgs(c.args...)
+				want, _ := c.builtin.(memdb.PrefixIndexer).PrefixFromArgs(c.args...)
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("PrefixFromArgs: got %v, want %v", got, want)
+				}
+			}
+		})
+	}
+
+	if _, _, err := (&ObjectIDIndex{}).FromObject(struct{}{}); err == nil {
+		t.Fatalf("expected error for another type")
+	}
+}
+
+func BenchmarkStringFieldIndex_FromObject(b *testing.B) {
+	obj := testObjects()[1]
+	indexer := &memdb.StringFieldIndex{Field: "ID"}
+	for i := 0; i < b.N; i++ {
+		indexer.FromObject(obj)
+	}
+}
+
+func BenchmarkGeneratedIndex_FromObject(b *testing.B) {
+	obj := testObjects()[1]
+	indexer := &ObjectIDIndex{}
+	for i := 0; i < b.N; i++ {
+		indexer.FromObject(obj)
+	}
+}
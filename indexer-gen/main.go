@@ -0,0 +1,431 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// This tool generates indexers for the fields of a struct type that don't
+// use reflection. The generated indexers produce exactly the same keys as
+// the reflection-based indexers of memdb, so they can be swapped in without
+// rebuilding any data. Use it from a go:generate directive in the package
+// declaring the type:
+//
+//	//go:generate go run github.com/hashicorp/go-memdb/indexer-gen -type Node ID Name Tags
+//
+// Supported fields are strings, string pointers and string slices, which
+// get the equivalent of a StringFieldIndex or StringSliceFieldIndex, as well
+// as integers, unsigned integers and booleans, which get the equivalent of
+// an IntFieldIndex, UintFieldIndex or BoolFieldIndex. Types defined from
+// those are supported too. If no field is given, indexers are generated for
+// all the supported exported fields.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// kind is the kind of indexer generated for a field.
+type kind int
+
+const (
+	kindString kind = iota
+	kindStringPtr
+	kindStringSlice
+	kindInt
+	kindUint
+	kindBool
+)
+
+// builtins gives the reflection-based memdb indexer equivalent to each kind.
+var builtins = map[kind]string{
+	kindString:      "StringFieldIndex",
+	kindStringPtr:   "StringFieldIndex",
+	kindStringSlice: "StringSliceFieldIndex",
+	kindInt:         "IntFieldIndex",
+	kindUint:        "UintFieldIndex",
+	kindBool:        "BoolFieldIndex",
+}
+
+// basicTypes gives the kind and encoded size of the supported basic types.
+// A zero size stands for the platform dependent size of int and uint.
+var basicTypes = map[string]struct {
+	kind kind
+	size int
+}{
+	"string": {kindString, 0},
+	"bool":   {kindBool, 1},
+	"int":    {kindInt, 0},
+	"int8":   {kindInt, 1},
+	"int16":  {kindInt, 2},
+	"int32":  {kindInt, 4},
+	"int64":  {kindInt, 8},
+	"uint":   {kindUint, 0},
+	"uint8":  {kindUint, 1},
+	"uint16": {kindUint, 2},
+	"uint32": {kindUint, 4},
+	"uint64": {kindUint, 8},
+	"byte":   {kindUint, 1},
+	"rune":   {kindInt, 4},
+}
+
+// field is a field to generate an indexer for.
+type field struct {
+	Name    string
+	Index   string
+	Kind    kind
+	Size    string
+	Builtin string
+}
+
+// IsString and the other helpers below are used by the template.
+func (f field) IsString() bool      { return f.Kind == kindString }
+func (f field) IsStringPtr() bool   { return f.Kind == kindStringPtr }
+func (f field) IsStringSlice() bool { return f.Kind == kindStringSlice }
+func (f field) IsInt() bool         { return f.Kind == kindInt }
+func (f field) IsUint() bool        { return f.Kind == kindUint }
+func (f field) IsBool() bool        { return f.Kind == kindBool }
+
+// params are the parameters of the template.
+type params struct {
+	Package string
+	Type    string
+	Fields  []field
+	Imports []string
+}
+
+// source is the template we use to generate the source file.
+const source = `// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Code generated by indexer-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+	"{{.}}"
+{{- end}}
+
+	"github.com/hashicorp/go-memdb"
+)
+
+// indexed{{.Type}} returns the {{.Type}} an object passed to an indexer refers to.
+func indexed{{.Type}}(obj interface{}) (*{{.Type}}, error) {
+	switch o := obj.(type) {
+	case *{{.Type}}:
+		if o == nil {
+			return nil, fmt.Errorf("object is a nil *{{.Type}}")
+		}
+		return o, nil
+	case {{.Type}}:
+		return &o, nil
+	default:
+		return nil, fmt.Errorf("object %#v is not of type {{.Type}}", obj)
+	}
+}
+{{range .Fields}}
+// {{.Index}} indexes the {{.Name}} field of {{$.Type}} without reflection. It
+// produces the same keys as a memdb.{{.Builtin}} on the same field.
+{{- if or .IsString .IsStringPtr .IsStringSlice}}
+type {{.Index}} struct {
+	Lowercase bool
+}
+{{- else}}
+type {{.Index}} struct{}
+{{- end}}
+
+{{if .IsStringSlice -}}
+var _ memdb.MultiIndexer = (*{{.Index}})(nil)
+{{- else -}}
+var _ memdb.SingleIndexer = (*{{.Index}})(nil)
+{{- end}}
+{{- if or .IsString .IsStringPtr .IsStringSlice}}
+var _ memdb.PrefixIndexer = (*{{.Index}})(nil)
+{{- end}}
+
+{{if .IsStringSlice -}}
+func (i *{{.Index}}) FromObject(obj interface{}) (bool, [][]byte, error) {
+	o, err := indexed{{$.Type}}(obj)
+	if err != nil {
+		return false, nil, err
+	}
+
+	vals := make([][]byte, 0, len(o.{{.Name}}))
+	for _, v := range o.{{.Name}} {
+		val := string(v)
+		if val == "" {
+			continue
+		}
+		if i.Lowercase {
+			val = strings.ToLower(val)
+		}
+
+		// Add the null character as a terminator
+		vals = append(vals, []byte(val+"\x00"))
+	}
+	if len(vals) == 0 {
+		return false, nil, nil
+	}
+	return true, vals, nil
+}
+{{- else -}}
+func (i *{{.Index}}) FromObject(obj interface{}) (bool, []byte, error) {
+	o, err := indexed{{$.Type}}(obj)
+	if err != nil {
+		return false, nil, err
+	}
+{{- if .IsStringPtr}}
+
+	if o.{{.Name}} == nil {
+		return false, []byte(""), nil
+	}
+	val := string(*o.{{.Name}})
+{{- else if .IsString}}
+
+	val := string(o.{{.Name}})
+{{- end}}
+{{- if or .IsString .IsStringPtr}}
+	if val == "" {
+		return false, nil, nil
+	}
+	if i.Lowercase {
+		val = strings.ToLower(val)
+	}
+
+	// Add the null character as a terminator
+	return true, []byte(val + "\x00"), nil
+{{- else if .IsInt}}
+	return true, memdb.EncodeInt(int64(o.{{.Name}}), {{.Size}}), nil
+{{- else if .IsUint}}
+	return true, memdb.EncodeUint(uint64(o.{{.Name}}), {{.Size}}), nil
+{{- else if .IsBool}}
+	if o.{{.Name}} {
+		return true, []byte{1}, nil
+	}
+	return true, []byte{0}, nil
+{{- end}}
+}
+{{- end}}
+
+func (i *{{.Index}}) FromArgs(args ...interface{}) ([]byte, error) {
+{{- if or .IsString .IsStringPtr .IsStringSlice}}
+	return (&memdb.{{.Builtin}}{Lowercase: i.Lowercase}).FromArgs(args...)
+{{- else}}
+	return (&memdb.{{.Builtin}}{}).FromArgs(args...)
+{{- end}}
+}
+{{if or .IsString .IsStringPtr .IsStringSlice}}
+func (i *{{.Index}}) PrefixFromArgs(args ...interface{}) ([]byte, error) {
+	return (&memdb.{{.Builtin}}{Lowercase: i.Lowercase}).PrefixFromArgs(args...)
+}
+{{end}}
+{{- end}}`
+
+// parsePackage parses the non-test Go files of a directory and returns the
+// package name along with its type declarations.
+func parsePackage(dir string) (string, map[string]ast.Expr, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(pkgs) != 1 {
+		return "", nil, fmt.Errorf("expected a single package in %s, found %d", dir, len(pkgs))
+	}
+
+	var name string
+	types := make(map[string]ast.Expr)
+	for pkgName, pkg := range pkgs {
+		name = pkgName
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					spec := spec.(*ast.TypeSpec)
+					types[spec.Name.Name] = spec.Type
+				}
+			}
+		}
+	}
+	return name, types, nil
+}
+
+// resolveBasic follows the type definitions of the package down to a
+// supported basic type.
+func resolveBasic(expr ast.Expr, types map[string]ast.Expr, depth int) (kind, int, bool) {
+	ident, ok := expr.(*ast.Ident)
+	if !ok || depth > len(types) {
+		return 0, 0, false
+	}
+	if basic, ok := basicTypes[ident.Name]; ok {
+		return basic.kind, basic.size, true
+	}
+	if underlying, ok := types[ident.Name]; ok {
+		return resolveBasic(underlying, types, depth+1)
+	}
+	return 0, 0, false
+}
+
+// fieldKind returns the kind of indexer to generate for a field type and
+// the expression of its encoded size.
+func fieldKind(expr ast.Expr, types map[string]ast.Expr) (kind, string, bool) {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		if k, _, ok := resolveBasic(expr.X, types, 0); ok && k == kindString {
+			return kindStringPtr, "", true
+		}
+	case *ast.ArrayType:
+		if k, _, ok := resolveBasic(expr.Elt, types, 0); ok && expr.Len == nil && k == kindString {
+			return kindStringSlice, "", true
+		}
+	default:
+		k, size, ok := resolveBasic(expr, types, 0)
+		if !ok {
+			return 0, "", false
+		}
+		if size == 0 {
+			return k, "strconv.IntSize / 8", true
+		}
+		return k, fmt.Sprintf("%d", size), true
+	}
+	return 0, "", false
+}
+
+// structFields returns the fields to generate indexers for.
+func structFields(typeName string, names []string, types map[string]ast.Expr) ([]field, error) {
+	expr, ok := types[typeName]
+	if !ok {
+		return nil, fmt.Errorf("type %s not found", typeName)
+	}
+	st, ok := expr.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("type %s is not a struct", typeName)
+	}
+
+	all := make(map[string]field)
+	var exported []string
+	for _, f := range st.Fields.List {
+		k, size, supported := fieldKind(f.Type, types)
+		for _, ident := range f.Names {
+			if !supported {
+				all[ident.Name] = field{Name: ident.Name, Kind: -1}
+				continue
+			}
+			all[ident.Name] = field{
+				Name:    ident.Name,
+				Index:   typeName + ident.Name + "Index",
+				Kind:    k,
+				Size:    size,
+				Builtin: builtins[k],
+			}
+			if ident.IsExported() {
+				exported = append(exported, ident.Name)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		names = exported
+	}
+	fields := make([]field, 0, len(names))
+	for _, name := range names {
+		f, ok := all[name]
+		if !ok {
+			return nil, fmt.Errorf("field %s not found in type %s", name, typeName)
+		}
+		if f.Kind < 0 {
+			return nil, fmt.Errorf("field %s of type %s has an unsupported type", name, typeName)
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no supported fields found in type %s", typeName)
+	}
+	return fields, nil
+}
+
+// render generates the source of the indexers.
+func render(p *params) ([]byte, error) {
+	imports := map[string]bool{"fmt": true}
+	for _, f := range p.Fields {
+		switch {
+		case f.IsString(), f.IsStringPtr(), f.IsStringSlice():
+			imports["strings"] = true
+		case strings.HasPrefix(f.Size, "strconv."):
+			imports["strconv"] = true
+		}
+	}
+	for imp := range imports {
+		p.Imports = append(p.Imports, imp)
+	}
+	sort.Strings(p.Imports)
+
+	tmpl, err := template.New("indexers").Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// generate returns the source of the indexers of the named fields of a
+// struct type declared in the package in dir, or of all its supported
+// fields if no name is given.
+func generate(dir, typeName string, names []string) ([]byte, error) {
+	pkg, types, err := parsePackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	if pkg == "memdb" {
+		return nil, fmt.Errorf("cannot generate indexers in package memdb")
+	}
+	fields, err := structFields(typeName, names, types)
+	if err != nil {
+		return nil, err
+	}
+	return render(&params{Package: pkg, Type: typeName, Fields: fields})
+}
+
+func run() error {
+	typeName := flag.String("type", "", "name of the struct type to generate indexers for")
+	output := flag.String("output", "", "output file name; default <type>_indexers.go")
+	dir := flag.String("dir", ".", "directory of the package declaring the type")
+	flag.Parse()
+	if *typeName == "" {
+		return fmt.Errorf("the -type flag is required")
+	}
+
+	src, err := generate(*dir, *typeName, flag.Args())
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = strings.ToLower(*typeName) + "_indexers.go"
+	}
+	return os.WriteFile(filepath.Join(*dir, *output), src, 0644)
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	} else {
+		os.Exit(0)
+	}
+}
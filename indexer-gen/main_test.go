@@ -0,0 +1,25 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestGenerate_Example(t *testing.T) {
+	// The committed example must match its go:generate directive
+	src, err := generate("example", "Object", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	committed, err := os.ReadFile("example/object_indexers.go")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !bytes.Equal(src, committed) {
+		t.Fatalf("example/object_indexers.go is out of date, run go generate ./indexer-gen/example")
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import "fmt"
+
+// JoinKeyFunc is used by Join and LeftJoin to build the lookup arguments for
+// the right-hand index from an object on the left-hand side. Returning an
+// empty slice means the left object does not reference any right-hand rows.
+type JoinKeyFunc func(left interface{}) ([]interface{}, error)
+
+// JoinResult is a single pair of objects emitted by a JoinIterator. Right is
+// nil when a LeftJoin finds no matching rows for Left.
+type JoinResult struct {
+	Left  interface{}
+	Right interface{}
+}
+
+// JoinIterator is used to iterate over the results of a join between two
+// tables. Rows from the left-hand table are read lazily, and for each of them
+// the related rows are looked up through the right-hand index.
+//
+// The same snapshot semantics as ResultIterator apply to both sides of the
+// join.
+type JoinIterator struct {
+	txn        *Txn
+	outer      bool
+	right      string
+	rightIndex string
+	keyFunc    JoinKeyFunc
+
+	// left is the iterator over the left-hand table.
+	left ResultIterator
+
+	// current is the left-hand object being joined, and rightIter iterates
+	// over its matches. matched tracks whether rightIter returned anything
+	// so left-outer joins know to emit an unmatched row.
+	current   interface{}
+	rightIter ResultIterator
+	matched   bool
+
+	// watches collects the watch channels of the left-hand iterator and
+	// every right-hand lookup performed so far.
+	watches WatchSet
+
+	err error
+}
+
+// Join is used to construct an inner join between two tables. The rows that
+// match the given constraints on the left-hand index are iterated over, and
+// keyFunc is used to derive the arguments used to look up the related rows
+// in the right-hand index. Only left-hand rows with at least one related row
+// are emitted, paired with each of the related rows.
+//
+// The right-hand side is always queried through its index; the right index
+// may use the "_prefix" suffix in the same way as Get.
+func (txn *Txn) Join(left, leftIndex, right, rightIndex string, keyFunc JoinKeyFunc, args ...interface{}) (*JoinIterator, error) {
+	return txn.join(false, left, leftIndex, right, rightIndex, keyFunc, args...)
+}
+
+// LeftJoin is used to construct a left-outer join between two tables. It
+// behaves like Join except that left-hand rows without any related rows are
+// emitted once with a nil Right.
+func (txn *Txn) LeftJoin(left, leftIndex, right, rightIndex string, keyFunc JoinKeyFunc, args ...interface{}) (*JoinIterator, error) {
+	return txn.join(true, left, leftIndex, right, rightIndex, keyFunc, args...)
+}
+
+func (txn *Txn) join(outer bool, left, leftIndex, right, rightIndex string, keyFunc JoinKeyFunc, args ...interface{}) (*JoinIterator, error) {
+	if keyFunc == nil {
+		return nil, fmt.Errorf("missing join key function")
+	}
+
+	// Validate the right-hand side up front so that a bad table or index
+	// isn't only discovered part way through iterating.
+	if _, _, err := txn.getIndexValue(right, rightIndex); err != nil {
+		return nil, err
+	}
+
+	leftIter, err := txn.Get(left, leftIndex, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	iter := &JoinIterator{
+		txn:        txn,
+		outer:      outer,
+		right:      right,
+		rightIndex: rightIndex,
+		keyFunc:    keyFunc,
+		left:       leftIter,
+		watches:    NewWatchSet(),
+	}
+	iter.watches.Add(leftIter.WatchCh())
+	return iter, nil
+}
+
+// Next returns the next pair of joined objects. If there are no more results
+// or an error occurred, nil is returned and Err reports the error, if any.
+func (j *JoinIterator) Next() *JoinResult {
+	for j.err == nil {
+		// Drain the matches of the current left-hand object first
+		if j.rightIter != nil {
+			if right := j.rightIter.Next(); right != nil {
+				j.matched = true
+				return &JoinResult{Left: j.current, Right: right}
+			}
+			j.rightIter = nil
+			if j.outer && !j.matched {
+				return &JoinResult{Left: j.current}
+			}
+		}
+
+		// Advance the left-hand side
+		left := j.left.Next()
+		if left == nil {
+			return nil
+		}
+		j.current = left
+		j.matched = false
+
+		args, err := j.keyFunc(left)
+		if err != nil {
+			j.err = fmt.Errorf("failed to build join key: %v", err)
+			return nil
+		}
+		if len(args) == 0 {
+			if j.outer {
+				return &JoinResult{Left: left}
+			}
+			continue
+		}
+
+		rightIter, err := j.txn.Get(j.right, j.rightIndex, args...)
+		if err != nil {
+			j.err = err
+			return nil
+		}
+		j.watches.Add(rightIter.WatchCh())
+		j.rightIter = rightIter
+	}
+	return nil
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (j *JoinIterator) Err() error {
+	return j.err
+}
+
+// WatchSet returns the watch channels of the left-hand iterator merged with
+// those of every right-hand lookup performed so far. Once the iterator has
+// been exhausted the set covers the entire result, and it fires when a
+// subsequent write transaction changes either side of the join.
+func (j *JoinIterator) WatchSet() WatchSet {
+	return j.watches
+}
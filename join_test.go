@@ -0,0 +1,187 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func testJoinDB(t *testing.T) (*MemDB, []*TestPerson, []*TestPlace) {
+	db := testComplexDB(t)
+	txn := db.Txn(true)
+
+	person1 := testPerson()
+	person2 := testPerson()
+	person2.First = "Mitchell"
+	person2.Last = "Hashimoto"
+	place1 := testPlace()
+	place2 := testPlace()
+	place2.Name = "Maui"
+
+	noErr(t, txn.Insert("people", person1))
+	noErr(t, txn.Insert("people", person2))
+	noErr(t, txn.Insert("places", place1))
+	noErr(t, txn.Insert("places", place2))
+	noErr(t, txn.Insert("visits", &TestVisit{person1.ID, place1.ID}))
+	noErr(t, txn.Insert("visits", &TestVisit{person1.ID, place2.ID}))
+	txn.Commit()
+
+	return db, []*TestPerson{person1, person2}, []*TestPlace{place1, place2}
+}
+
+func personVisits(left interface{}) ([]interface{}, error) {
+	return []interface{}{left.(*TestPerson).ID}, nil
+}
+
+func collectJoin(t *testing.T, iter *JoinIterator) []*JoinResult {
+	t.Helper()
+	var out []*JoinResult
+	for res := iter.Next(); res != nil; res = iter.Next() {
+		out = append(out, res)
+	}
+	noErr(t, iter.Err())
+	return out
+}
+
+func TestTxn_Join(t *testing.T) {
+	db, people, places := testJoinDB(t)
+	txn := db.Txn(false)
+
+	iter, err := txn.Join("people", "id", "visits", "id_prefix", personVisits, people[0].ID)
+	noErr(t, err)
+	results := collectJoin(t, iter)
+	if len(results) != 2 {
+		t.Fatalf("bad: %#v", results)
+	}
+	seen := make(map[string]bool)
+	for _, res := range results {
+		if res.Left != people[0] {
+			t.Fatalf("bad left: %#v", res.Left)
+		}
+		seen[res.Right.(*TestVisit).Place] = true
+	}
+	if !seen[places[0].ID] || !seen[places[1].ID] {
+		t.Fatalf("bad: %v", seen)
+	}
+
+	// A person without visits isn't part of an inner join
+	iter, err = txn.Join("people", "id", "visits", "id_prefix", personVisits, people[1].ID)
+	noErr(t, err)
+	if results := collectJoin(t, iter); len(results) != 0 {
+		t.Fatalf("bad: %#v", results)
+	}
+
+	// Join across all rows of the left-hand table
+	iter, err = txn.Join("people", "id", "visits", "id_prefix", personVisits)
+	noErr(t, err)
+	if results := collectJoin(t, iter); len(results) != 2 {
+		t.Fatalf("bad: %#v", results)
+	}
+}
+
+func TestTxn_LeftJoin(t *testing.T) {
+	db, people, _ := testJoinDB(t)
+	txn := db.Txn(false)
+
+	iter, err := txn.LeftJoin("people", "id", "visits", "id_prefix", personVisits)
+	noErr(t, err)
+	results := collectJoin(t, iter)
+	if len(results) != 3 {
+		t.Fatalf("bad: %#v", results)
+	}
+
+	var unmatched int
+	for _, res := range results {
+		if res.Right == nil {
+			unmatched++
+			if res.Left != people[1] {
+				t.Fatalf("bad left: %#v", res.Left)
+			}
+		}
+	}
+	if unmatched != 1 {
+		t.Fatalf("bad: %d", unmatched)
+	}
+
+	// An empty key emits the left row on its own
+	noKey := func(interface{}) ([]interface{}, error) { return nil, nil }
+	iter, err = txn.LeftJoin("people", "id", "visits", "id_prefix", noKey, people[0].ID)
+	noErr(t, err)
+	results = collectJoin(t, iter)
+	if len(results) != 1 || results[0].Left != people[0] || results[0].Right != nil {
+		t.Fatalf("bad: %#v", results)
+	}
+}
+
+func TestTxn_Join_Errors(t *testing.T) {
+	db, _, _ := testJoinDB(t)
+	txn := db.Txn(false)
+
+	if _, err := txn.Join("people", "id", "visits", "id", nil); err == nil {
+		t.Fatalf("expected error for missing key func")
+	}
+	if _, err := txn.Join("people", "id", "nope", "id", personVisits); err == nil {
+		t.Fatalf("expected error for invalid right table")
+	}
+	if _, err := txn.Join("people", "id", "visits", "nope", personVisits); err == nil {
+		t.Fatalf("expected error for invalid right index")
+	}
+	if _, err := txn.Join("nope", "id", "visits", "id", personVisits); err == nil {
+		t.Fatalf("expected error for invalid left table")
+	}
+
+	failing := func(interface{}) ([]interface{}, error) { return nil, fmt.Errorf("boom") }
+	iter, err := txn.Join("people", "id", "visits", "id", failing)
+	noErr(t, err)
+	if res := iter.Next(); res != nil {
+		t.Fatalf("bad: %#v", res)
+	}
+	if iter.Err() == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestTxn_Join_WriteTxn(t *testing.T) {
+	db, people, places := testJoinDB(t)
+	txn := db.Txn(true)
+	defer txn.Abort()
+
+	// Uncommitted writes on either side are visible to the join
+	noErr(t, txn.Insert("visits", &TestVisit{people[1].ID, places[1].ID}))
+	iter, err := txn.Join("people", "id", "visits", "id_prefix", personVisits, people[1].ID)
+	noErr(t, err)
+	if results := collectJoin(t, iter); len(results) != 1 {
+		t.Fatalf("bad: %#v", results)
+	}
+}
+
+func TestTxn_Join_Watch(t *testing.T) {
+	db, people, places := testJoinDB(t)
+	txn := db.Txn(false)
+
+	iter, err := txn.LeftJoin("people", "id", "visits", "id_prefix", personVisits, people[1].ID)
+	noErr(t, err)
+	collectJoin(t, iter)
+	ws := iter.WatchSet()
+
+	// Changing an unrelated table should not fire
+	wtxn := db.Txn(true)
+	place := testPlace()
+	place.Name = "Elsewhere"
+	noErr(t, wtxn.Insert("places", place))
+	wtxn.Commit()
+	if timeout := ws.Watch(time.After(50 * time.Millisecond)); !timeout {
+		t.Fatalf("should timeout")
+	}
+
+	// Adding a row on the right-hand side of the join should fire
+	wtxn = db.Txn(true)
+	noErr(t, wtxn.Insert("visits", &TestVisit{people[1].ID, places[0].ID}))
+	wtxn.Commit()
+	if timeout := ws.Watch(time.After(time.Second)); timeout {
+		t.Fatalf("should not timeout")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// tableLocks holds a writer lock for each table. Writers lock the tables
+// they write to in sorted order, so writers of disjoint tables can run
+// concurrently without deadlocking each other.
+type tableLocks struct {
+	// tables is the sorted list of all the tables.
+	tables []string
+
+	// locks is held by sending to the channel of a table and released by
+	// receiving from it.
+	locks map[string]chan struct{}
+}
+
+// newTableLocks creates the locks for the tables of the schema.
+func newTableLocks(schema *DBSchema) *tableLocks {
+	l := &tableLocks{
+		locks: make(map[string]chan struct{}, len(schema.Tables)),
+	}
+	for name := range schema.Tables {
+		l.tables = append(l.tables, name)
+		l.locks[name] = make(chan struct{}, 1)
+	}
+	sort.Strings(l.tables)
+	return l
+}
+
+// lock acquires the locks of the given sorted tables. When try is set it
+// gives up instead of waiting for a held lock and returns false. Otherwise it
+// waits until the context is done and returns the context's error. No lock
+// is held when lock fails.
+func (l *tableLocks) lock(ctx context.Context, tables []string, try bool) (bool, error) {
+	for i, table := range tables {
+		var err error
+		if try {
+			select {
+			case l.locks[table] <- struct{}{}:
+				continue
+			default:
+			}
+		} else {
+			select {
+			case l.locks[table] <- struct{}{}:
+				continue
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		l.unlock(tables[:i])
+		return false, err
+	}
+	return true, nil
+}
+
+// unlock releases the locks of the given tables.
+func (l *tableLocks) unlock(tables []string) {
+	for _, table := range tables {
+		<-l.locks[table]
+	}
+}
+
+// writeTables returns the sorted and deduplicated list of the given tables,
+// and an error if one of them isn't part of the schema.
+func (l *tableLocks) writeTables(tables []string) ([]string, error) {
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no tables given")
+	}
+
+	sorted := make([]string, 0, len(tables))
+	seen := make(map[string]struct{}, len(tables))
+	for _, table := range tables {
+		if _, ok := l.locks[table]; !ok {
+			return nil, fmt.Errorf("invalid table '%s'", table)
+		}
+		if _, ok := seen[table]; ok {
+			continue
+		}
+		seen[table] = struct{}{}
+		sorted = append(sorted, table)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// checkLocked returns an error if the transaction doesn't hold the writer
+// lock of the given table. Transactions created with WriteTxn only lock the
+// tables they were created for.
+func (txn *Txn) checkLocked(table string) error {
+	if txn.tables == nil {
+		return nil
+	}
+	if _, ok := txn.tables[table]; !ok {
+		return fmt.Errorf("table '%s' is not locked by this transaction", table)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemDB_WriteTxn_Disjoint(t *testing.T) {
+	db, err := NewMemDB(testPreCommitSchema())
+	noErr(t, err)
+
+	read := db.Txn(false)
+	mainWatch, _ := read.TableIndexWatch("main")
+	otherWatch, _ := read.TableIndexWatch("other")
+
+	tx1, err := db.WriteTxn("main")
+	noErr(t, err)
+
+	// A writer of another table doesn't wait
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		tx2, err := db.WriteTxn("other", "other")
+		if err != nil {
+			t.Errorf("err: %v", err)
+			return
+		}
+		if err := tx2.Insert("other", &TestObject{ID: "b"}); err != nil {
+			t.Errorf("err: %v", err)
+		}
+		tx2.Commit()
+	}()
+	select {
+	case <-doneCh:
+	case <-time.After(time.Second):
+		t.Fatalf("should allow a writer of another table")
+	}
+
+	// Writers of the same table or of all tables wait
+	if _, ok := db.TryWriteTxn(); ok {
+		t.Fatalf("should not allow another writer")
+	}
+	blockedCh := make(chan struct{})
+	go func() {
+		defer close(blockedCh)
+		db.WriteTxn("other", "main")
+	}()
+	select {
+	case <-blockedCh:
+		t.Fatalf("should not allow another writer of main")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// The commit is merged with the one of the other writer
+	noErr(t, tx1.Insert("main", testSavepointObj("a", "x")))
+	tx1.Commit()
+	select {
+	case <-blockedCh:
+	case <-time.After(time.Second):
+		t.Fatalf("should allow another writer of main")
+	}
+
+	read = db.Txn(false)
+	if raw, _ := read.First("main", "id", "a"); raw == nil {
+		t.Fatalf("main object should exist")
+	}
+	if raw, _ := read.First("other", "id", "b"); raw == nil {
+		t.Fatalf("other object should exist")
+	}
+	if idx := read.CommitIndex(); idx != 2 {
+		t.Fatalf("bad: %d", idx)
+	}
+	if read.TableIndex("main") != 2 || read.TableIndex("other") != 1 {
+		t.Fatalf("bad: %d %d", read.TableIndex("main"), read.TableIndex("other"))
+	}
+	for name, ch := range map[string]<-chan struct{}{"main": mainWatch, "other": otherWatch} {
+		select {
+		case <-ch:
+		default:
+			t.Fatalf("watch for %q should fire", name)
+		}
+	}
+}
+
+func TestMemDB_WriteTxn_Confined(t *testing.T) {
+	db, err := NewMemDB(testPreCommitSchema())
+	noErr(t, err)
+
+	if _, err := db.WriteTxn(); err == nil {
+		t.Fatalf("expected error without tables")
+	}
+	if _, err := db.WriteTxn("nope"); err == nil {
+		t.Fatalf("expected error for unknown table")
+	}
+
+	txn, err := db.WriteTxn("other")
+	noErr(t, err)
+	defer txn.Abort()
+	if err := txn.Insert("main", testSavepointObj("a", "x")); err == nil {
+		t.Fatalf("expected error for undeclared table")
+	}
+	if err := txn.Delete("main", testSavepointObj("a", "x")); err == nil {
+		t.Fatalf("expected error for undeclared table")
+	}
+	if _, err := txn.DeletePrefix("main", "id_prefix", "a"); err == nil {
+		t.Fatalf("expected error for undeclared table")
+	}
+	noErr(t, txn.Insert("other", &TestObject{ID: "b"}))
+}
+
+func TestMemDB_WriteTxn_ForeignKey(t *testing.T) {
+	db, node, _ := testForeignKeyDB(t, Cascade)
+
+	// Cascading into an undeclared table fails
+	txn, err := db.WriteTxn("nodes")
+	noErr(t, err)
+	if err := txn.Delete("nodes", node); err == nil {
+		t.Fatalf("expected error for undeclared referencing table")
+	}
+	txn.Abort()
+
+	txn, err = db.WriteTxn("allocs")
+	noErr(t, err)
+	if err := txn.Insert("allocs", &TestAlloc{ID: "alloc2", NodeID: node.ID}); err == nil {
+		t.Fatalf("expected error for undeclared referenced table")
+	}
+	txn.Abort()
+
+	txn, err = db.WriteTxn("nodes", "allocs")
+	noErr(t, err)
+	noErr(t, txn.Delete("nodes", node))
+	txn.Commit()
+}
@@ -0,0 +1,191 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	// lruIndex is the name of the internal index of each LRU table, mapping
+	// a use sequence number followed by the primary key to the rows, from
+	// the least to the most recently used. lruKeyIndex maps the primary key
+	// of each row to its key in lruIndex.
+	lruIndex    = "\x00lru"
+	lruKeyIndex = "\x00lrukey"
+)
+
+// LRUSchema makes a table a bounded cache: inserting a new row into a full
+// table evicts its least recently used rows. Evictions are performed by the
+// inserting write transaction and recorded as deletes in its Changes, so
+// older snapshots and read transactions still see the evicted rows.
+// Optimistic transactions only evict when their writes are replayed at
+// commit, according to the use order at that time.
+type LRUSchema struct {
+	// Capacity is the maximum number of rows of the table.
+	Capacity int
+
+	// TrackReads makes the rows returned by reads of the table count as
+	// used, including reads from read transactions. Since only write
+	// transactions can modify the table, reads are buffered and applied
+	// by the next insert that evicts rows. Reads are tracked on a best
+	// effort basis: they are dropped while the buffer holds Capacity rows,
+	// and by transactions that are aborted after applying them.
+	TrackReads bool
+}
+
+// Validate is used to validate the LRU schema.
+func (s *LRUSchema) Validate() error {
+	if s.Capacity <= 0 {
+		return fmt.Errorf("capacity must be positive")
+	}
+	return nil
+}
+
+// lruReads buffers the rows of a table read since they were last applied,
+// along with the order of their last read.
+type lruReads struct {
+	l    sync.Mutex
+	max  int
+	seq  uint64
+	rows map[string]uint64
+}
+
+// newLRUReads returns the read buffers of the tables tracking reads.
+func newLRUReads(schema *DBSchema) map[string]*lruReads {
+	var reads map[string]*lruReads
+	for name, tableSchema := range schema.Tables {
+		if tableSchema.LRU == nil || !tableSchema.LRU.TrackReads {
+			continue
+		}
+		if reads == nil {
+			reads = make(map[string]*lruReads)
+		}
+		reads[name] = &lruReads{
+			max:  tableSchema.LRU.Capacity,
+			rows: make(map[string]uint64),
+		}
+	}
+	return reads
+}
+
+// add records a read of the row with the given primary key.
+func (r *lruReads) add(idVal []byte) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	if _, ok := r.rows[string(idVal)]; !ok && len(r.rows) >= r.max {
+		return
+	}
+	r.seq++
+	r.rows[string(idVal)] = r.seq
+}
+
+// drain returns the primary keys of the buffered rows in the order of their
+// last read, and empties the buffer.
+func (r *lruReads) drain() []string {
+	r.l.Lock()
+	rows := r.rows
+	r.rows = make(map[string]uint64, len(rows))
+	r.l.Unlock()
+
+	ids := make([]string, 0, len(rows))
+	for idVal := range rows {
+		ids = append(ids, idVal)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return rows[ids[i]] < rows[ids[j]]
+	})
+	return ids
+}
+
+// recordUse records a read of an object of a table, if the table tracks
+// reads.
+func (txn *Txn) recordUse(table string, obj interface{}) {
+	reads, ok := txn.db.lruReads[table]
+	if !ok || obj == nil {
+		return
+	}
+	if idVal, err := txn.primaryKey(table, obj); err == nil {
+		reads.add(idVal)
+	}
+}
+
+// useRecorder returns a function recording reads of a table, or nil if the
+// table doesn't track reads.
+func (txn *Txn) useRecorder(table string) func(obj interface{}) {
+	if _, ok := txn.db.lruReads[table]; !ok {
+		return nil
+	}
+	return func(obj interface{}) {
+		txn.recordUse(table, obj)
+	}
+}
+
+// touch makes a row the most recently used row of its table.
+func (txn *Txn) touch(table string, idVal []byte, obj interface{}) {
+	useTxn := txn.writableIndex(table, lruIndex)
+	keyTxn := txn.writableIndex(table, lruKeyIndex)
+	if old, ok := keyTxn.Get(idVal); ok {
+		useTxn.Delete(old.([]byte))
+	}
+
+	var seq uint64
+	if last, _, ok := useTxn.Root().Maximum(); ok {
+		seq = binary.BigEndian.Uint64(last) + 1
+	}
+	key := binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(idVal)), seq)
+	key = append(key, idVal...)
+	useTxn.Insert(key, obj)
+	keyTxn.Insert(idVal, key)
+}
+
+// forget removes a deleted row from the use order of its table.
+func (txn *Txn) forget(table string, idVal []byte) {
+	keyTxn := txn.writableIndex(table, lruKeyIndex)
+	if old, ok := keyTxn.Get(idVal); ok {
+		txn.writableIndex(table, lruIndex).Delete(old.([]byte))
+		keyTxn.Delete(idVal)
+	}
+}
+
+// lruVictims returns the least recently used rows of a table to evict for
+// a new row to fit in it, without deleting them. The buffered reads are
+// applied first.
+func (txn *Txn) lruVictims(tableSchema *TableSchema) []interface{} {
+	table := tableSchema.Name
+	excess := txn.usage(table).Rows - tableSchema.LRU.Capacity + 1
+	if excess <= 0 {
+		return nil
+	}
+
+	if reads, ok := txn.db.lruReads[table]; ok {
+		idTxn := txn.writableIndex(table, id)
+		for _, idVal := range reads.drain() {
+			if obj, ok := idTxn.Get([]byte(idVal)); ok {
+				txn.touch(table, []byte(idVal), obj)
+			}
+		}
+	}
+
+	victims := make([]interface{}, 0, excess)
+	iter := txn.writableIndex(table, lruIndex).Root().Iterator()
+	for _, obj, ok := iter.Next(); ok && len(victims) < excess; _, obj, ok = iter.Next() {
+		victims = append(victims, obj)
+	}
+	return victims
+}
+
+// evict deletes the rows of a table returned by lruVictims. A row may
+// already be gone through a foreign key action of another one.
+func (txn *Txn) evict(table string, victims []interface{}) error {
+	for _, obj := range victims {
+		if err := txn.delete(table, obj); err != nil && err != ErrNotFound {
+			return fmt.Errorf("failed to evict row of table '%s': %w", table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func testLRUDB(t *testing.T, lru *LRUSchema) *MemDB {
+	t.Helper()
+	schema := testValidSchema()
+	schema.Tables["main"].LRU = lru
+	db, err := NewMemDB(schema)
+	noErr(t, err)
+	return db
+}
+
+func testLRUInsert(t *testing.T, db *MemDB, ids ...string) Changes {
+	t.Helper()
+	txn := db.Txn(true)
+	txn.TrackChanges()
+	for _, id := range ids {
+		noErr(t, txn.Insert("main", testSavepointObj(id, "x")))
+	}
+	txn.Commit()
+	return txn.Changes()
+}
+
+func testLRUIDs(t *testing.T, txn *Txn) string {
+	t.Helper()
+	iter, err := txn.Get("main", "id")
+	noErr(t, err)
+	var ids []string
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		ids = append(ids, raw.(*TestObject).ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestLRU_Evict(t *testing.T) {
+	db := testLRUDB(t, &LRUSchema{Capacity: 2})
+	testLRUInsert(t, db, "a", "b")
+	before := db.Txn(false)
+
+	changes := testLRUInsert(t, db, "c")
+	if len(changes) != 2 || changes[0].Before.(*TestObject).ID != "a" || !changes[0].Deleted() ||
+		changes[1].After.(*TestObject).ID != "c" {
+		t.Fatalf("bad changes: %#v", changes)
+	}
+	if ids := testLRUIDs(t, db.Txn(false)); ids != "b,c" {
+		t.Fatalf("bad rows: %s", ids)
+	}
+
+	// Older transactions still see the evicted row
+	if ids := testLRUIDs(t, before); ids != "a,b" {
+		t.Fatalf("bad rows before eviction: %s", ids)
+	}
+
+	// Updates make a row the most recently used
+	testLRUInsert(t, db, "b", "d")
+	if ids := testLRUIDs(t, db.Txn(false)); ids != "b,d" {
+		t.Fatalf("bad rows: %s", ids)
+	}
+
+	// Deletes make room without evicting
+	txn := db.Txn(true)
+	noErr(t, txn.Delete("main", testSavepointObj("b", "x")))
+	txn.Commit()
+	testLRUInsert(t, db, "e")
+	if ids := testLRUIDs(t, db.Txn(false)); ids != "d,e" {
+		t.Fatalf("bad rows: %s", ids)
+	}
+}
+
+func TestLRU_EvictWithinTxn(t *testing.T) {
+	db := testLRUDB(t, &LRUSchema{Capacity: 2})
+
+	txn := db.Txn(true)
+	txn.TrackChanges()
+	for _, id := range []string{"a", "b", "c", "d"} {
+		noErr(t, txn.Insert("main", testSavepointObj(id, "x")))
+	}
+	if ids := testLRUIDs(t, txn); ids != "c,d" {
+		t.Fatalf("bad rows: %s", ids)
+	}
+	txn.Commit()
+
+	// Changes collapse the rows created and evicted by the transaction
+	changes := txn.Changes()
+	if len(changes) != 2 || !changes[0].Created() || !changes[1].Created() {
+		t.Fatalf("bad changes: %#v", changes)
+	}
+}
+
+func TestLRU_TrackReads(t *testing.T) {
+	for _, trackReads := range []bool{false, true} {
+		db := testLRUDB(t, &LRUSchema{Capacity: 3, TrackReads: trackReads})
+		testLRUInsert(t, db, "a", "b", "c")
+
+		// Read a with a lookup and b with an iterator
+		txn := db.Txn(false)
+		raw, err := txn.First("main", "id", "a")
+		noErr(t, err)
+		if raw == nil {
+			t.Fatalf("missing row")
+		}
+		iter, err := txn.Get("main", "id_prefix", "b")
+		noErr(t, err)
+		for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		}
+
+		testLRUInsert(t, db, "d")
+		expected := "b,c,d"
+		if trackReads {
+			expected = "a,b,d"
+		}
+		if ids := testLRUIDs(t, db.Txn(false)); ids != expected {
+			t.Fatalf("bad rows with track reads %v: %s", trackReads, ids)
+		}
+	}
+}
+
+func TestLRU_BulkLoad(t *testing.T) {
+	db := testLRUDB(t, &LRUSchema{Capacity: 2})
+	testLRUInsert(t, db, "a")
+
+	// BulkLoad doesn't evict, the next insert evicts down to the capacity
+	noErr(t, db.BulkLoad("main", []interface{}{
+		testSavepointObj("b", "x"),
+		testSavepointObj("a", "y"),
+		testSavepointObj("c", "x"),
+	}))
+	if ids := testLRUIDs(t, db.Txn(false)); ids != "a,b,c" {
+		t.Fatalf("bad rows: %s", ids)
+	}
+	testLRUInsert(t, db, "d")
+	if ids := testLRUIDs(t, db.Txn(false)); ids != "c,d" {
+		t.Fatalf("bad rows: %s", ids)
+	}
+}
+
+func TestLRU_Apply(t *testing.T) {
+	db := testLRUDB(t, &LRUSchema{Capacity: 2})
+	testLRUInsert(t, db, "a", "b")
+
+	// Applied rows evict like inserted ones
+	txn := db.Txn(true)
+	noErr(t, txn.Apply(Changes{{Table: "main", After: testSavepointObj("c", "x")}}))
+	txn.Commit()
+	if ids := testLRUIDs(t, db.Txn(false)); ids != "b,c" {
+		t.Fatalf("bad rows: %s", ids)
+	}
+}
+
+func TestLRU_Quota(t *testing.T) {
+	schema := testValidSchema()
+	schema.Tables["main"].LRU = &LRUSchema{Capacity: 2}
+	schema.Tables["main"].Quota = &QuotaSchema{MaxBytes: 3, Size: testQuotaSize}
+	db, err := NewMemDB(schema)
+	noErr(t, err)
+	testLRUInsert(t, db, "a", "b")
+
+	// A rejected insert doesn't evict anything
+	txn := db.Txn(true)
+	assertQuotaError(t, txn.Insert("main", testSavepointObj("c", "12345")), "bytes", 3, 6)
+	if ids := testLRUIDs(t, txn); ids != "a,b" {
+		t.Fatalf("bad rows: %s", ids)
+	}
+	assertUsage(t, txn, "main", 2, 2)
+
+	// The bytes of the evicted row are freed for the new one
+	noErr(t, txn.Insert("main", testSavepointObj("c", "12")))
+	if ids := testLRUIDs(t, txn); ids != "b,c" {
+		t.Fatalf("bad rows: %s", ids)
+	}
+	assertUsage(t, txn, "main", 2, 3)
+	txn.Commit()
+}
+
+func TestLRU_Optimistic(t *testing.T) {
+	db := testLRUDB(t, &LRUSchema{Capacity: 2})
+	testLRUInsert(t, db, "a", "b")
+
+	txn := db.OptimisticTxn()
+	noErr(t, txn.Insert("main", testSavepointObj("c", "x")))
+	testLRUInsert(t, db, "a")
+	noErr(t, txn.CommitErr())
+
+	// The eviction is replayed against the current use order
+	if ids := testLRUIDs(t, db.Txn(false)); ids != "a,c" {
+		t.Fatalf("bad rows: %s", ids)
+	}
+}
+
+func TestLRUSchema_Validate(t *testing.T) {
+	schema := testValidSchema()
+	schema.Tables["main"].LRU = &LRUSchema{}
+	if err := schema.Validate(); err == nil {
+		t.Fatalf("expected error for zero capacity")
+	}
+}
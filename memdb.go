@@ -6,8 +6,10 @@
 package memdb
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/hashicorp/go-immutable-radix"
@@ -31,12 +33,41 @@ type MemDB struct {
 	root    unsafe.Pointer // *iradix.Tree underneath
 	primary bool
 
-	// There can only be a single writer at once
-	writer sync.Mutex
+	// foreignKeys holds the resolved foreign keys of the schema, indexed by
+	// referencing table, and references indexes them by referenced table.
+	foreignKeys map[string][]*foreignKey
+	references  map[string][]*foreignKey
+
+	// preCommit is set when the schema declares pre-commit hooks.
+	preCommit bool
+
+	// There can only be a single writer of each table at once. Writers hold
+	// the locks of the tables they write to, and commitLock while updating
+	// the root.
+	locks      *tableLocks
+	commitLock sync.Mutex
+
+	// history holds the retained roots, if enabled.
+	history *history
+
+	// events publishes the changes of every commit, if enabled.
+	events *publisher
+
+	// budget is the memory budget of the database, or zero if unlimited.
+	budget int64
+
+	// lruReads buffers the reads of the LRU tables tracking reads.
+	lruReads map[string]*lruReads
+
+	// metrics receives the metrics of the database, if enabled.
+	metrics MetricsSink
 }
 
-// NewMemDB creates a new MemDB with the given schema.
-func NewMemDB(schema *DBSchema) (*MemDB, error) {
+// Option configures optional behavior of a MemDB.
+type Option func(*MemDB)
+
+// NewMemDB creates a new MemDB with the given schema and options.
+func NewMemDB(schema *DBSchema, opts ...Option) (*MemDB, error) {
 	// Validate the schema
 	if err := schema.Validate(); err != nil {
 		return nil, err
@@ -47,10 +78,20 @@ func NewMemDB(schema *DBSchema) (*MemDB, error) {
 		schema:  schema,
 		root:    unsafe.Pointer(iradix.New()),
 		primary: true,
+		locks:   newTableLocks(schema),
+	}
+	db.foreignKeys, db.references = resolveForeignKeys(schema)
+	db.preCommit = hasPreCommit(schema)
+	db.lruReads = newLRUReads(schema)
+	for _, opt := range opts {
+		opt(db)
 	}
 	if err := db.initialize(); err != nil {
 		return nil, err
 	}
+	if db.history != nil {
+		db.history.add(0, db.getRoot(), time.Now())
+	}
 
 	return db, nil
 }
@@ -71,18 +112,95 @@ func (db *MemDB) getRoot() *iradix.Tree {
 
 // Txn is used to start a new transaction in either read or write mode.
 // There can only be a single concurrent writer, but any number of readers.
+// A write transaction started with Txn excludes all the other writers; use
+// WriteTxn to only lock some of the tables.
 func (db *MemDB) Txn(write bool) *Txn {
-	if write {
-		db.writer.Lock()
+	txn, _ := db.TxnCtx(context.Background(), write)
+	return txn
+}
+
+// TxnCtx is like Txn but gives up waiting for the writer lock when the
+// context is done, in which case the context's error is returned. Read
+// transactions never wait.
+func (db *MemDB) TxnCtx(ctx context.Context, write bool) (*Txn, error) {
+	if !write {
+		return db.newTxn(false, nil, 0), nil
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	if _, err := db.locks.lock(ctx, db.locks.tables, false); err != nil {
+		return nil, err
+	}
+	wait := time.Since(start)
+	db.observeWait(wait)
+	return db.newTxn(true, nil, wait), nil
+}
+
+// TryWriteTxn starts a write transaction only if no other writer holds the
+// lock. It returns false without blocking otherwise.
+func (db *MemDB) TryWriteTxn() (*Txn, bool) {
+	if ok, _ := db.locks.lock(context.Background(), db.locks.tables, true); !ok {
+		return nil, false
+	}
+	db.observeWait(0)
+	return db.newTxn(true, nil, 0), true
+}
+
+// WriteTxn starts a write transaction that only locks the given tables, so
+// that it can run concurrently with writers of other tables. Writing to any
+// other table fails, including through foreign key actions and pre-commit
+// hooks. Reads of other tables see the database as it was when the
+// transaction started.
+//
+// Tables referenced by a foreign key of a written table, or referencing it,
+// must be given too so that the foreign key can be enforced.
+func (db *MemDB) WriteTxn(tables ...string) (*Txn, error) {
+	tables, err := db.locks.writeTables(tables)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	db.locks.lock(context.Background(), tables, false)
+	wait := time.Since(start)
+	db.observeWait(wait)
+	return db.newTxn(true, tables, wait), nil
+}
+
+// newTxn creates a transaction. The writer locks of the given tables, or of
+// all tables if nil, must already be held for a write transaction.
+func (db *MemDB) newTxn(write bool, tables []string, wait time.Duration) *Txn {
 	txn := &Txn{
 		db:      db,
 		write:   write,
 		rootTxn: db.getRoot().Txn(),
+		wait:    wait,
+	}
+	if write {
+		txn.locked = db.locks.tables
+		if tables != nil {
+			txn.locked = tables
+			txn.tables = make(map[string]struct{}, len(tables))
+			for _, table := range tables {
+				txn.tables[table] = struct{}{}
+			}
+		}
+	}
+	if write && (db.preCommit || db.events != nil) {
+		txn.TrackChanges()
 	}
 	return txn
 }
 
+// readTxn creates a read transaction against the given root.
+func (db *MemDB) readTxn(root *iradix.Tree) *Txn {
+	return &Txn{
+		db:      db,
+		rootTxn: root.Txn(),
+	}
+}
+
 // Snapshot is used to capture a point-in-time snapshot  of the database that
 // will not be affected by any write operations to the existing DB.
 //
@@ -91,9 +209,23 @@ func (db *MemDB) Txn(write bool) *Txn {
 // to modify any inserted values in either DB.
 func (db *MemDB) Snapshot() *MemDB {
 	clone := &MemDB{
-		schema:  db.schema,
-		root:    unsafe.Pointer(db.getRoot()),
-		primary: false,
+		schema:      db.schema,
+		root:        unsafe.Pointer(db.getRoot()),
+		primary:     false,
+		foreignKeys: db.foreignKeys,
+		references:  db.references,
+		preCommit:   db.preCommit,
+		locks:       newTableLocks(db.schema),
+		budget:      db.budget,
+		lruReads:    newLRUReads(db.schema),
+		metrics:     db.metrics,
+	}
+	root := clone.getRoot()
+	if db.history != nil {
+		clone.history = db.history.snapshot(rootCommitIndex(root), root)
+	}
+	if db.events != nil {
+		clone.events = db.events.snapshot(rootCommitIndex(root))
 	}
 	return clone
 }
@@ -108,6 +240,22 @@ func (db *MemDB) initialize() error {
 			path := indexPath(tName, iName)
 			root, _, _ = root.Insert(path, index)
 		}
+
+		// Add the commit index of the table and its internal indexes
+		root, _, _ = root.Insert(indexPath(tName, tableIndexName), uint64(0))
+		if tableSchema.Versioned {
+			root, _, _ = root.Insert(indexPath(tName, versionIndex), iradix.New())
+		}
+		if tableSchema.TTL != nil {
+			root, _, _ = root.Insert(indexPath(tName, ttlIndex), iradix.New())
+		}
+		if db.tracksUsage(tableSchema) {
+			root, _, _ = root.Insert(indexPath(tName, usageIndex), iradix.New())
+		}
+		if tableSchema.LRU != nil {
+			root, _, _ = root.Insert(indexPath(tName, lruIndex), iradix.New())
+			root, _, _ = root.Insert(indexPath(tName, lruKeyIndex), iradix.New())
+		}
 	}
 	db.root = unsafe.Pointer(root)
 	return nil
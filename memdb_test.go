@@ -4,6 +4,7 @@
 package memdb
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -86,3 +87,71 @@ func TestMemDB_Snapshot(t *testing.T) {
 		t.Fatalf("should exist")
 	}
 }
+
+func TestMemDB_TxnCtx(t *testing.T) {
+	db := testDB(t)
+
+	tx1, err := db.TxnCtx(context.Background(), true)
+	noErr(t, err)
+
+	// Readers don't wait for the writer
+	read, err := db.TxnCtx(context.Background(), false)
+	noErr(t, err)
+	read.Abort()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := db.TxnCtx(ctx, true); err != context.DeadlineExceeded {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// A cancelled context is honoured even if the lock is free
+	tx1.Abort()
+	if _, err := db.TxnCtx(ctx, true); err != context.DeadlineExceeded {
+		t.Fatalf("bad: %v", err)
+	}
+
+	doneCh := make(chan *Txn)
+	tx1 = db.Txn(true)
+	go func() {
+		txn, err := db.TxnCtx(context.Background(), true)
+		if err != nil {
+			t.Errorf("err: %v", err)
+		}
+		doneCh <- txn
+	}()
+	time.Sleep(20 * time.Millisecond)
+	tx1.Commit()
+
+	select {
+	case tx2 := <-doneCh:
+		if tx2.WaitTime() < 20*time.Millisecond {
+			t.Fatalf("bad wait time: %v", tx2.WaitTime())
+		}
+		tx2.Abort()
+	case <-time.After(time.Second):
+		t.Fatalf("should allow another writer")
+	}
+}
+
+func TestMemDB_TryWriteTxn(t *testing.T) {
+	db := testDB(t)
+
+	tx1, ok := db.TryWriteTxn()
+	if !ok {
+		t.Fatalf("should get the writer lock")
+	}
+	if _, ok := db.TryWriteTxn(); ok {
+		t.Fatalf("should not allow another writer")
+	}
+	tx1.Abort()
+
+	// Snapshots have their own writer lock
+	tx1 = db.Txn(true)
+	defer tx1.Abort()
+	tx2, ok := db.Snapshot().TryWriteTxn()
+	if !ok {
+		t.Fatalf("should get the snapshot writer lock")
+	}
+	tx2.Abort()
+}
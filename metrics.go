@@ -0,0 +1,257 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"encoding/json"
+	"expvar"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+// The names of the metrics reported to a MetricsSink. Durations are in
+// milliseconds.
+const (
+	// MetricTxnWait samples the time write transactions waited for the
+	// writer lock.
+	MetricTxnWait = "memdb.txn.wait"
+
+	// MetricTxnCommit samples the time taken by commits, including the
+	// pre-commit hooks and the replay of optimistic transactions.
+	MetricTxnCommit = "memdb.txn.commit"
+
+	// MetricTxnAbort counts the aborted write transactions.
+	MetricTxnAbort = "memdb.txn.abort"
+
+	// MetricInsert and MetricDelete count the rows inserted and deleted,
+	// labeled by table. Rows written by foreign key actions and evictions
+	// are included.
+	MetricInsert = "memdb.insert"
+	MetricDelete = "memdb.delete"
+
+	// MetricCommitKeys samples the number of keys written to each index by
+	// a commit, labeled by table and index.
+	MetricCommitKeys = "memdb.commit.keys"
+
+	// MetricCommitNotify samples the number of watch channels closed in
+	// each index by a commit, labeled by table and index.
+	MetricCommitNotify = "memdb.commit.notify"
+
+	// MetricWatchChannels samples the number of channels of the watch sets
+	// being waited on, and MetricWatchWait the time waited.
+	MetricWatchChannels = "memdb.watch.channels"
+	MetricWatchWait     = "memdb.watch.wait"
+
+	// MetricWatchFired and MetricWatchCancelled count the waits on watch
+	// sets that returned because a channel was closed, and because the
+	// context was done.
+	MetricWatchFired     = "memdb.watch.fired"
+	MetricWatchCancelled = "memdb.watch.cancelled"
+)
+
+// Label is the name and value of a dimension of a metric.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// MetricsSink receives the metrics of a database, see WithMetrics. Its
+// methods are called from the goroutines using the database, so they must
+// be safe for concurrent use and should be fast.
+type MetricsSink interface {
+	// IncrCounter adds delta to a counter.
+	IncrCounter(name string, labels []Label, delta int64)
+
+	// AddSample adds a value to a histogram.
+	AddSample(name string, labels []Label, value float64)
+}
+
+// WithMetrics makes the database report its metrics to the given sink, see
+// the Metric constants. Without a sink no metric is computed.
+func WithMetrics(sink MetricsSink) Option {
+	return func(db *MemDB) {
+		db.metrics = sink
+	}
+}
+
+// watchMetrics holds the sink of the metrics of watch sets.
+var watchMetrics atomic.Pointer[MetricsSink]
+
+// SetWatchMetrics makes every WatchSet report its metrics to the given
+// sink, or to none if it is nil. Watch sets aren't tied to a database, so
+// they don't use the sink given to WithMetrics.
+func SetWatchMetrics(sink MetricsSink) {
+	if sink == nil {
+		watchMetrics.Store(nil)
+		return
+	}
+	watchMetrics.Store(&sink)
+}
+
+// milliseconds converts a duration to a metric value.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// observeWait reports the time a write transaction waited for the writer
+// lock.
+func (db *MemDB) observeWait(wait time.Duration) {
+	if db.metrics != nil {
+		db.metrics.AddSample(MetricTxnWait, nil, milliseconds(wait))
+	}
+}
+
+// tableLabels returns the labels of a metric of a table.
+func tableLabels(table string) []Label {
+	return []Label{{Name: "table", Value: table}}
+}
+
+// keyCounter returns the counter of the keys written to an index by the
+// transaction, or nil if metrics aren't enabled or the index is internal.
+func (txn *Txn) keyCounter(key tableIndex) *int {
+	if txn.db.metrics == nil || strings.HasPrefix(key.Index, "\x00") {
+		return nil
+	}
+	if txn.keys == nil {
+		txn.keys = make(map[tableIndex]*int)
+	}
+	count, ok := txn.keys[key]
+	if !ok {
+		count = new(int)
+		txn.keys[key] = count
+	}
+	return count
+}
+
+// indexDiff holds the roots of an index before and after a commit.
+type indexDiff struct {
+	before, after *iradix.Node
+}
+
+// reportCommit reports the number of keys written to each index, and the
+// number of watch channels closed in each of the given modified indexes.
+func (txn *Txn) reportCommit(diffs map[tableIndex]indexDiff) {
+	metrics := txn.db.metrics
+	for key, count := range txn.keys {
+		metrics.AddSample(MetricCommitKeys, []Label{
+			{Name: "table", Value: key.Table},
+			{Name: "index", Value: key.Index},
+		}, float64(*count))
+	}
+
+	for key, diff := range diffs {
+		if strings.HasPrefix(key.Index, "\x00") {
+			continue
+		}
+		metrics.AddSample(MetricCommitNotify, []Label{
+			{Name: "table", Value: key.Table},
+			{Name: "index", Value: key.Index},
+		}, float64(closedWatches(diff.before, diff.after)))
+	}
+}
+
+// closedWatches returns the number of watch channels of the old version of
+// a radix tree closed by committing the new one: those of the nodes copied
+// on the path to each changed key, and of the leaves written or deleted.
+func closedWatches(old, new *iradix.Node) int {
+	closed := make(map[<-chan struct{}]struct{})
+	diffTrees(old, new, nil, nil, func(ch <-chan struct{}) {
+		closed[ch] = struct{}{}
+	})
+	return len(closed)
+}
+
+// ExpvarSink is a MetricsSink publishing the metrics with the expvar
+// package, as a map with one entry per metric and set of labels. Counters
+// are integers, and histograms are objects holding the count, sum, minimum
+// and maximum of their samples.
+type ExpvarSink struct {
+	vars *expvar.Map
+	l    sync.Mutex
+}
+
+// NewExpvarSink returns a sink publishing the metrics under the given name.
+// Like expvar.Publish, it panics if the name is already in use.
+func NewExpvarSink(name string) *ExpvarSink {
+	return &ExpvarSink{vars: expvar.NewMap(name)}
+}
+
+// Map returns the published map of metrics.
+func (s *ExpvarSink) Map() *expvar.Map {
+	return s.vars
+}
+
+// IncrCounter implements MetricsSink.
+func (s *ExpvarSink) IncrCounter(name string, labels []Label, delta int64) {
+	s.vars.Add(expvarKey(name, labels), delta)
+}
+
+// AddSample implements MetricsSink.
+func (s *ExpvarSink) AddSample(name string, labels []Label, value float64) {
+	key := expvarKey(name, labels)
+	hist, ok := s.vars.Get(key).(*expvarHistogram)
+	if !ok {
+		s.l.Lock()
+		if hist, ok = s.vars.Get(key).(*expvarHistogram); !ok {
+			hist = new(expvarHistogram)
+			s.vars.Set(key, hist)
+		}
+		s.l.Unlock()
+	}
+	hist.add(value)
+}
+
+// expvarKey returns the key of a metric with the given labels, such as
+// "memdb.commit.keys{index=id,table=main}".
+func expvarKey(name string, labels []Label) string {
+	if len(labels) == 0 {
+		return name
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = label.Name + "=" + label.Value
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// expvarHistogram summarizes the samples of a histogram.
+type expvarHistogram struct {
+	l     sync.Mutex
+	count int64
+	sum   float64
+	min   float64
+	max   float64
+}
+
+func (h *expvarHistogram) add(value float64) {
+	h.l.Lock()
+	defer h.l.Unlock()
+	if h.count == 0 || value < h.min {
+		h.min = value
+	}
+	if h.count == 0 || value > h.max {
+		h.max = value
+	}
+	h.count++
+	h.sum += value
+}
+
+// String implements expvar.Var.
+func (h *expvarHistogram) String() string {
+	h.l.Lock()
+	defer h.l.Unlock()
+	out, _ := json.Marshal(map[string]interface{}{
+		"count": h.count,
+		"sum":   h.sum,
+		"min":   h.min,
+		"max":   h.max,
+	})
+	return string(out)
+}
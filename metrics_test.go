@@ -0,0 +1,240 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+// testSink records the metrics reported to it by key, see expvarKey.
+type testSink struct {
+	l        sync.Mutex
+	counters map[string]int64
+	samples  map[string][]float64
+}
+
+func newTestSink() *testSink {
+	return &testSink{
+		counters: make(map[string]int64),
+		samples:  make(map[string][]float64),
+	}
+}
+
+func (s *testSink) IncrCounter(name string, labels []Label, delta int64) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.counters[expvarKey(name, labels)] += delta
+}
+
+func (s *testSink) AddSample(name string, labels []Label, value float64) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	key := expvarKey(name, labels)
+	s.samples[key] = append(s.samples[key], value)
+}
+
+func (s *testSink) counter(key string) int64 {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.counters[key]
+}
+
+func (s *testSink) sampled(key string) []float64 {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.samples[key]
+}
+
+func TestMetrics(t *testing.T) {
+	sink := newTestSink()
+	db, err := NewMemDB(testValidSchema(), WithMetrics(sink))
+	noErr(t, err)
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	noErr(t, txn.Delete("main", testSavepointObj("a", "x")))
+	txn.Commit()
+
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("c", "x")))
+	txn.Abort()
+
+	if n := sink.counter("memdb.insert{table=main}"); n != 3 {
+		t.Fatalf("bad inserts: %d", n)
+	}
+	if n := sink.counter("memdb.delete{table=main}"); n != 1 {
+		t.Fatalf("bad deletes: %d", n)
+	}
+	if n := sink.counter(MetricTxnAbort); n != 1 {
+		t.Fatalf("bad aborts: %d", n)
+	}
+	if n := len(sink.sampled(MetricTxnWait)); n != 2 {
+		t.Fatalf("bad wait samples: %d", n)
+	}
+	if n := len(sink.sampled(MetricTxnCommit)); n != 1 {
+		t.Fatalf("bad commit samples: %d", n)
+	}
+
+	// Two inserts and a delete for the id index, one insert and a delete
+	// for each value of qux
+	keys := sink.sampled("memdb.commit.keys{index=id,table=main}")
+	if len(keys) != 1 || keys[0] != 3 {
+		t.Fatalf("bad id keys: %v", keys)
+	}
+	keys = sink.sampled("memdb.commit.keys{index=qux,table=main}")
+	if len(keys) != 1 || keys[0] != 3 {
+		t.Fatalf("bad qux keys: %v", keys)
+	}
+
+	// Internal indexes aren't reported
+	for key := range sink.samples {
+		if strings.Contains(key, "\x00") {
+			t.Fatalf("bad key: %q", key)
+		}
+	}
+
+	// The second commit closes the channels of the nodes it replaces
+	read := db.Txn(false)
+	watch, _, err := read.FirstWatch("main", "id", "b")
+	noErr(t, err)
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("b", "y")))
+	txn.Commit()
+	<-watch
+	notify := sink.sampled("memdb.commit.notify{index=id,table=main}")
+	if len(notify) != 2 || notify[1] < 2 {
+		t.Fatalf("bad notify samples: %v", notify)
+	}
+}
+
+func TestClosedWatches(t *testing.T) {
+	keys := []string{"", "a", "aa", "aab", "aac", "ab", "b", "ba", "c"}
+	cases := []struct {
+		name   string
+		insert []string
+		delete []string
+	}{
+		{"none", nil, nil},
+		{"update", []string{"aab"}, nil},
+		{"insert", []string{"aad", "d"}, nil},
+		{"delete", nil, []string{"ab", "c"}},
+		{"root", []string{""}, []string{"b"}},
+		{"split", []string{"aaba", "bb"}, []string{"aac"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := iradix.New()
+			for _, k := range keys {
+				tree, _, _ = tree.Insert([]byte(k), k)
+			}
+
+			// Watch every node and leaf of the tree
+			watches := make(map[<-chan struct{}]struct{})
+			for _, k := range keys {
+				for i := 0; i <= len(k); i++ {
+					iter := tree.Root().Iterator()
+					watches[iter.SeekPrefixWatch([]byte(k[:i]))] = struct{}{}
+				}
+				watch, _, _ := tree.Root().GetWatch([]byte(k))
+				watches[watch] = struct{}{}
+			}
+
+			indexTxn := tree.Txn()
+			indexTxn.TrackMutate(true)
+			for _, k := range tc.insert {
+				indexTxn.Insert([]byte(k), "new")
+			}
+			for _, k := range tc.delete {
+				indexTxn.Delete([]byte(k))
+			}
+			final := indexTxn.Commit()
+
+			closed := 0
+			for watch := range watches {
+				select {
+				case <-watch:
+					closed++
+				default:
+				}
+			}
+			if n := closedWatches(tree.Root(), final.Root()); n != closed {
+				t.Fatalf("bad closed watches: %d, expected %d", n, closed)
+			}
+		})
+	}
+}
+
+func TestMetrics_Disabled(t *testing.T) {
+	db := testDB(t)
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	if txn.keys != nil {
+		t.Fatalf("keys should not be counted")
+	}
+	txn.Commit()
+}
+
+func TestWatchSet_Metrics(t *testing.T) {
+	sink := newTestSink()
+	SetWatchMetrics(sink)
+	defer SetWatchMetrics(nil)
+
+	closed := make(chan struct{})
+	close(closed)
+	ws := NewWatchSet()
+	ws.Add(closed)
+	noErr(t, ws.WatchCtx(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ws = NewWatchSet()
+	ws.Add(make(chan struct{}))
+	ws.Add(make(chan struct{}))
+	if err := ws.WatchCtx(ctx); err == nil {
+		t.Fatalf("expected cancellation")
+	}
+
+	if n := sink.counter(MetricWatchFired); n != 1 {
+		t.Fatalf("bad fired: %d", n)
+	}
+	if n := sink.counter(MetricWatchCancelled); n != 1 {
+		t.Fatalf("bad cancelled: %d", n)
+	}
+	if channels := sink.sampled(MetricWatchChannels); len(channels) != 2 || channels[0] != 1 || channels[1] != 2 {
+		t.Fatalf("bad channels: %v", channels)
+	}
+	if n := len(sink.sampled(MetricWatchWait)); n != 2 {
+		t.Fatalf("bad wait samples: %d", n)
+	}
+}
+
+func TestExpvarSink(t *testing.T) {
+	sink := NewExpvarSink("memdb_test_metrics")
+	labels := []Label{{Name: "table", Value: "main"}, {Name: "index", Value: "id"}}
+	sink.IncrCounter("count", labels, 2)
+	sink.IncrCounter("count", labels, 3)
+	sink.AddSample("hist", nil, 4)
+	sink.AddSample("hist", nil, 1)
+
+	if v := sink.Map().Get("count{index=id,table=main}"); v == nil || v.String() != "5" {
+		t.Fatalf("bad counter: %v", v)
+	}
+
+	var hist struct {
+		Count    int64
+		Sum      float64
+		Min, Max float64
+	}
+	noErr(t, json.Unmarshal([]byte(sink.Map().Get("hist").String()), &hist))
+	if hist.Count != 2 || hist.Sum != 5 || hist.Min != 1 || hist.Max != 4 {
+		t.Fatalf("bad histogram: %#v", hist)
+	}
+}
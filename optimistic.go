@@ -0,0 +1,194 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+var (
+	// ErrConflict is returned when committing an optimistic transaction
+	// that read data modified by another transaction since it started. The
+	// transaction can be retried.
+	ErrConflict = fmt.Errorf("transaction conflict")
+)
+
+// readKey identifies a range of keys read from an index.
+type readKey struct {
+	Table  string
+	Index  string
+	Prefix string
+}
+
+// readRange is a range of keys read by an optimistic transaction, as it was
+// in the root the transaction started from.
+type readRange struct {
+	// watch is the watch channel of the radix node the prefix falls under.
+	// Nodes are copied with a new channel whenever they are modified, so an
+	// unchanged channel means the range is unchanged.
+	watch <-chan struct{}
+
+	// root is the root of the index, used to compare the range key by key
+	// when the node was modified.
+	root *iradix.Node
+}
+
+// optimisticState holds the read set and the write log of an optimistic
+// transaction.
+type optimisticState struct {
+	reads map[readKey]readRange
+
+	// ops are the top-level writes of the transaction, replayed at commit.
+	// depth is the nesting level of the write being performed, so that
+	// writes made by other writes, like foreign key actions, aren't logged.
+	ops   []func(txn *Txn) error
+	depth int
+}
+
+// OptimisticTxn starts a write transaction that doesn't take the writer
+// lock. Its reads and writes are performed against the database as it was
+// when the transaction started, and the index ranges it reads are recorded.
+//
+// At commit the transaction takes the writer lock and checks that none of
+// the ranges it read were modified by a transaction that committed in the
+// meantime. If so ErrConflict is returned and the transaction should be
+// retried. Otherwise its writes are replayed on top of the current state of
+// the database and committed, including running the pre-commit hooks.
+//
+// Exact lookups and prefix scans are validated key by key. Range scans with
+// LowerBound, ReverseLowerBound and LongestPrefix conflict with any write to
+// the index.
+func (db *MemDB) OptimisticTxn() *Txn {
+	txn := db.newTxn(true, nil, 0)
+	txn.locked = nil
+	txn.optimistic = &optimisticState{
+		reads: make(map[readKey]readRange),
+	}
+	return txn
+}
+
+// recordRead records that keys under the given prefix of an index were read
+// by an optimistic transaction. A nil prefix covers the whole index.
+func (txn *Txn) recordRead(table, index string, prefix []byte) {
+	if txn.optimistic == nil {
+		return
+	}
+	key := readKey{Table: table, Index: index, Prefix: string(prefix)}
+	if _, ok := txn.optimistic.reads[key]; ok {
+		return
+	}
+
+	// The read set refers to the root the transaction started from, not to
+	// the modifications made by the transaction itself
+	raw, _ := txn.rootTxn.Get(indexPath(table, index))
+	root := raw.(*iradix.Tree).Root()
+	watch := root.Iterator().SeekPrefixWatch(prefix)
+	txn.optimistic.reads[key] = readRange{watch: watch, root: root}
+}
+
+// recordWrite performs a write and logs it to be replayed at commit if it is
+// a top-level write of an optimistic transaction.
+func (txn *Txn) recordWrite(op func(txn *Txn) error) error {
+	if txn.optimistic == nil {
+		return op(txn)
+	}
+
+	txn.optimistic.depth++
+	err := op(txn)
+	txn.optimistic.depth--
+	if err == nil && txn.optimistic.depth == 0 {
+		txn.optimistic.ops = append(txn.optimistic.ops, op)
+	}
+	return err
+}
+
+// conflicts returns whether any of the ranges read by the transaction were
+// modified in the current root of the database. The writer locks must be
+// held.
+func (s *optimisticState) conflicts(db *MemDB) bool {
+	root := db.getRoot()
+	for key, read := range s.reads {
+		raw, _ := root.Get(indexPath(key.Table, key.Index))
+		current := raw.(*iradix.Tree).Root()
+		prefix := []byte(key.Prefix)
+		if current.Iterator().SeekPrefixWatch(prefix) == read.watch {
+			continue
+		}
+		if !sameRange(read.root, current, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameRange compares the keys under the given prefix of two radix trees.
+// Leaves are compared by the identity of their watch channel, which changes
+// whenever a key is updated.
+func sameRange(a, b *iradix.Node, prefix []byte) bool {
+	iterA, iterB := a.Iterator(), b.Iterator()
+	iterA.SeekPrefix(prefix)
+	iterB.SeekPrefix(prefix)
+	for {
+		keyA, _, okA := iterA.Next()
+		keyB, _, okB := iterB.Next()
+		if okA != okB {
+			return false
+		}
+		if !okA {
+			return true
+		}
+		if !bytes.Equal(keyA, keyB) {
+			return false
+		}
+		watchA, _, _ := a.GetWatch(keyA)
+		watchB, _, _ := b.GetWatch(keyB)
+		if watchA != watchB {
+			return false
+		}
+	}
+}
+
+// commitOptimistic validates the read set of an optimistic transaction and
+// replays its writes in a new write transaction.
+func (txn *Txn) commitOptimistic() error {
+	state := txn.optimistic
+	txn.rootTxn = nil
+	txn.modified = nil
+	txn.savepoints = nil
+	txn.optimistic = nil
+
+	// Committed transactions have notified their watches before releasing
+	// the writer locks, so holding them makes the watches reliable.
+	db := txn.db
+	_, _ = db.locks.lock(context.Background(), db.locks.tables, false)
+	if state.conflicts(db) {
+		db.locks.unlock(db.locks.tables)
+		txn.changes = nil
+		return ErrConflict
+	}
+
+	replay := db.newTxn(true, nil, 0)
+	if txn.changes != nil {
+		replay.TrackChanges()
+	}
+	for _, op := range state.ops {
+		if err := op(replay); err != nil {
+			replay.Abort()
+			txn.changes = nil
+			return err
+		}
+	}
+	replay.after = txn.after
+
+	if err := replay.CommitErr(); err != nil {
+		txn.changes = nil
+		return err
+	}
+	txn.changes = replay.changes
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"testing"
+)
+
+func TestMemDB_OptimisticTxn(t *testing.T) {
+	db := testDB(t)
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	txn.Commit()
+
+	// Optimistic transactions don't take the writer lock
+	writer := db.Txn(true)
+	tx1 := db.OptimisticTxn()
+	tx2 := db.OptimisticTxn()
+	writer.Abort()
+
+	deferred := false
+	tx1.Defer(func() { deferred = true })
+	raw, err := tx1.First("main", "id", "a")
+	noErr(t, err)
+	updated := *raw.(*TestObject)
+	updated.Foo = "y"
+	noErr(t, tx1.Insert("main", &updated))
+
+	raw, err = tx2.First("main", "id", "a")
+	noErr(t, err)
+	if raw.(*TestObject).Foo != "x" {
+		t.Fatalf("should read from the start snapshot: %#v", raw)
+	}
+	noErr(t, tx2.Insert("main", testSavepointObj("a", "z")))
+
+	// First committer wins
+	noErr(t, tx1.CommitErr())
+	if !deferred {
+		t.Fatalf("deferred functions should run")
+	}
+	if err := tx2.CommitErr(); err != ErrConflict {
+		t.Fatalf("bad: %v", err)
+	}
+
+	read := db.Txn(false)
+	raw, err = read.First("main", "id", "a")
+	noErr(t, err)
+	if raw.(*TestObject).Foo != "y" {
+		t.Fatalf("bad: %#v", raw)
+	}
+	if idx := read.CommitIndex(); idx != 2 {
+		t.Fatalf("bad: %d", idx)
+	}
+
+	// The writer lock was released after the conflict
+	txn = db.Txn(true)
+	txn.Abort()
+}
+
+func TestMemDB_OptimisticTxn_Disjoint(t *testing.T) {
+	db := testDB(t)
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	txn.Commit()
+
+	tx1 := db.OptimisticTxn()
+	tx1.TrackChanges()
+	noErr(t, tx1.Insert("main", testSavepointObj("b", "x")))
+	sp := tx1.Savepoint()
+	noErr(t, tx1.Insert("main", testSavepointObj("d", "x")))
+	noErr(t, tx1.RollbackTo(sp))
+
+	// A concurrent write to rows that weren't read doesn't conflict, and
+	// is preserved by the replay
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("c", "x")))
+	txn.Commit()
+
+	noErr(t, tx1.CommitErr())
+	if changes := tx1.Changes(); len(changes) != 1 {
+		t.Fatalf("bad: %#v", changes)
+	}
+
+	read := db.Txn(false)
+	assertExists(t, read, map[string]bool{"a": true, "b": true, "c": true, "d": false})
+}
+
+func TestMemDB_OptimisticTxn_Range(t *testing.T) {
+	db := testDB(t)
+
+	// An iteration over an index conflicts with inserts into it
+	tx1 := db.OptimisticTxn()
+	iter, err := tx1.Get("main", "foo", "x")
+	noErr(t, err)
+	if raw := iter.Next(); raw != nil {
+		t.Fatalf("bad: %#v", raw)
+	}
+	noErr(t, tx1.Insert("main", testSavepointObj("a", "x")))
+
+	tx2 := db.OptimisticTxn()
+	noErr(t, tx2.Insert("main", testSavepointObj("b", "x")))
+	noErr(t, tx2.CommitErr())
+
+	if err := tx1.CommitErr(); err != ErrConflict {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestMemDB_OptimisticTxn_ForeignKey(t *testing.T) {
+	db, node, alloc := testForeignKeyDB(t, Cascade)
+
+	// Cascades are replayed as part of the delete that caused them
+	tx1 := db.OptimisticTxn()
+	noErr(t, tx1.Delete("nodes", node))
+	noErr(t, tx1.CommitErr())
+
+	read := db.Txn(false)
+	if raw, _ := read.First("allocs", "id", alloc.ID); raw != nil {
+		t.Fatalf("alloc should be deleted: %#v", raw)
+	}
+}
+
+func TestMemDB_OptimisticTxn_Snapshot(t *testing.T) {
+	db := testDB(t)
+	snap := db.Snapshot()
+
+	tx1 := snap.OptimisticTxn()
+	_, err := tx1.First("main", "id", "a")
+	noErr(t, err)
+	noErr(t, tx1.Insert("main", testSavepointObj("a", "x")))
+
+	// Snapshots don't track mutations, but conflicts are still detected
+	txn := snap.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "y")))
+	txn.Commit()
+
+	if err := tx1.CommitErr(); err != ErrConflict {
+		t.Fatalf("bad: %v", err)
+	}
+}
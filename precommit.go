@@ -0,0 +1,74 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"fmt"
+	"sort"
+)
+
+// PreCommitFunc is a hook run by a write transaction before it is committed.
+// It receives the transaction and the changes made so far, as returned by
+// Txn.Changes. Hooks may read through the transaction and perform extra
+// writes, which are committed along with the rest of the transaction.
+// Returning an error aborts the whole transaction.
+//
+// Change tracking is always enabled for write transactions on a database
+// whose schema declares pre-commit hooks.
+type PreCommitFunc func(txn *Txn, changes Changes) error
+
+// hasPreCommit returns whether the schema declares any pre-commit hooks.
+func hasPreCommit(schema *DBSchema) bool {
+	if len(schema.PreCommit) > 0 {
+		return true
+	}
+	for _, table := range schema.Tables {
+		if len(table.PreCommit) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// preCommit runs the pre-commit hooks of the schema. Database-level hooks
+// run first, followed by the hooks of each changed table in table name
+// order. Writes made by a hook are visible to the hooks that run after it,
+// and the hooks of a table only changed by another hook are run as well.
+func (txn *Txn) preCommit() error {
+	for _, hook := range txn.db.schema.PreCommit {
+		if err := hook(txn, txn.Changes()); err != nil {
+			return fmt.Errorf("pre-commit hook failed: %w", err)
+		}
+	}
+
+	// Run the hooks of each changed table once. The changes are regrouped
+	// after every table since hooks may write to tables that haven't been
+	// visited yet.
+	done := make(map[string]struct{})
+	for {
+		byTable := make(map[string]Changes)
+		for _, change := range txn.Changes() {
+			if _, ok := done[change.Table]; !ok {
+				byTable[change.Table] = append(byTable[change.Table], change)
+			}
+		}
+		if len(byTable) == 0 {
+			return nil
+		}
+
+		tables := make([]string, 0, len(byTable))
+		for table := range byTable {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+
+		table := tables[0]
+		done[table] = struct{}{}
+		for _, hook := range txn.db.schema.Tables[table].PreCommit {
+			if err := hook(txn, byTable[table]); err != nil {
+				return fmt.Errorf("pre-commit hook for table %q failed: %w", table, err)
+			}
+		}
+	}
+}
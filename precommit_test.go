@@ -0,0 +1,165 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"errors"
+	"testing"
+)
+
+var errTwoLeaders = errors.New("more than one leader")
+
+// testOneLeader is a pre-commit hook allowing at most one object per Foo
+// group to have Bool set.
+func testOneLeader(txn *Txn, changes Changes) error {
+	for _, change := range changes {
+		if change.After == nil || change.Table != "main" {
+			continue
+		}
+		group := change.After.(*TestObject).Foo
+		iter, err := txn.Get("main", "foo", group)
+		if err != nil {
+			return err
+		}
+		leaders := 0
+		for raw := iter.Next(); raw != nil; raw = iter.Next() {
+			if raw.(*TestObject).Bool {
+				leaders++
+			}
+		}
+		if leaders > 1 {
+			return errTwoLeaders
+		}
+	}
+	return nil
+}
+
+func testPreCommitSchema() *DBSchema {
+	schema := testValidSchema()
+	schema.Tables["other"] = &TableSchema{
+		Name: "other",
+		Indexes: map[string]*IndexSchema{
+			"id": &IndexSchema{
+				Name:    "id",
+				Unique:  true,
+				Indexer: &StringFieldIndex{Field: "ID"},
+			},
+		},
+	}
+	schema.PreCommit = []PreCommitFunc{testOneLeader}
+	return schema
+}
+
+func TestDBSchema_Validate_PreCommit(t *testing.T) {
+	s := testPreCommitSchema()
+	noErr(t, s.Validate())
+
+	s.PreCommit = append(s.PreCommit, nil)
+	if err := s.Validate(); err == nil {
+		t.Fatalf("should not validate, nil hook")
+	}
+
+	s = testPreCommitSchema()
+	s.Tables["main"].PreCommit = []PreCommitFunc{nil}
+	if err := s.Validate(); err == nil {
+		t.Fatalf("should not validate, nil table hook")
+	}
+}
+
+func TestTxn_PreCommit_Veto(t *testing.T) {
+	db, err := NewMemDB(testPreCommitSchema())
+	noErr(t, err)
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", &TestObject{ID: "a", Foo: "g", Bool: true, Qux: []string{"q"}}))
+	noErr(t, txn.CommitErr())
+
+	deferred := false
+	txn = db.Txn(true)
+	txn.Defer(func() { deferred = true })
+	noErr(t, txn.Insert("main", &TestObject{ID: "b", Foo: "g", Bool: true, Qux: []string{"q"}}))
+	if err := txn.CommitErr(); !errors.Is(err, errTwoLeaders) {
+		t.Fatalf("bad: %v", err)
+	}
+	if deferred {
+		t.Fatalf("deferred functions should not run")
+	}
+
+	// The transaction was aborted and the writer released
+	txn = db.Txn(true)
+	if raw, _ := txn.First("main", "id", "b"); raw != nil {
+		t.Fatalf("should not exist: %#v", raw)
+	}
+
+	// Commit aborts silently
+	noErr(t, txn.Insert("main", &TestObject{ID: "c", Foo: "g", Bool: true, Qux: []string{"q"}}))
+	txn.Commit()
+	txn = db.Txn(false)
+	if raw, _ := txn.First("main", "id", "c"); raw != nil {
+		t.Fatalf("should not exist: %#v", raw)
+	}
+
+	// Committing again is a noop
+	wtxn := db.Txn(true)
+	noErr(t, wtxn.CommitErr())
+	noErr(t, wtxn.CommitErr())
+}
+
+func TestTxn_PreCommit_Writes(t *testing.T) {
+	schema := testPreCommitSchema()
+
+	var mainChanges, otherChanges Changes
+	schema.Tables["main"].PreCommit = []PreCommitFunc{
+		func(txn *Txn, changes Changes) error {
+			mainChanges = changes
+
+			// Extra writes are committed with the transaction
+			for _, change := range changes {
+				obj := change.After.(*TestObject)
+				if err := txn.Insert("other", &TestObject{ID: obj.ID}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	schema.Tables["other"].PreCommit = []PreCommitFunc{
+		func(txn *Txn, changes Changes) error {
+			otherChanges = changes
+			return nil
+		},
+	}
+
+	db, err := NewMemDB(schema)
+	noErr(t, err)
+
+	txn := db.Txn(true)
+	obj := &TestObject{ID: "a", Foo: "g", Qux: []string{"q"}}
+	noErr(t, txn.Insert("main", obj))
+	noErr(t, txn.CommitErr())
+
+	if len(mainChanges) != 1 || mainChanges[0].After != obj {
+		t.Fatalf("bad: %#v", mainChanges)
+	}
+	if len(otherChanges) != 1 || otherChanges[0].Table != "other" {
+		t.Fatalf("bad: %#v", otherChanges)
+	}
+
+	txn = db.Txn(false)
+	if raw, _ := txn.First("other", "id", "a"); raw == nil {
+		t.Fatalf("extra write should be committed")
+	}
+
+	// Table hooks only run for changed tables
+	mainChanges, otherChanges = nil, nil
+	txn = db.Txn(true)
+	if txn.Changes() == nil {
+		t.Fatalf("change tracking should be enabled")
+	}
+	noErr(t, txn.Insert("other", &TestObject{ID: "b"}))
+	noErr(t, txn.CommitErr())
+	if mainChanges != nil || len(otherChanges) != 1 {
+		t.Fatalf("bad: %#v %#v", mainChanges, otherChanges)
+	}
+}
@@ -0,0 +1,382 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"fmt"
+	"reflect"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+const (
+	// usageIndex is the name of the internal index of each table whose usage
+	// is tracked, holding a single TableUsage under usageKey.
+	usageIndex = "\x00usage"
+)
+
+var (
+	// ErrQuotaExceeded is returned, wrapped in a *QuotaError, by Txn.Insert
+	// when an object doesn't fit in the quota of its table or in the memory
+	// budget of the database.
+	ErrQuotaExceeded = fmt.Errorf("quota exceeded")
+
+	// usageKey is the key of the usage of a table in its usage index.
+	usageKey = []byte("usage")
+)
+
+// QuotaSchema limits the number of rows of a table and the approximate
+// memory used by its objects. A zero limit is unlimited.
+type QuotaSchema struct {
+	// MaxRows is the maximum number of rows of the table.
+	MaxRows int
+
+	// MaxBytes is the maximum sum of the sizes of the objects of the table,
+	// as returned by Size.
+	MaxBytes int64
+
+	// Size returns the approximate size of an object in bytes. It defaults
+	// to EstimateSize. It must always return the same size for an object.
+	Size func(obj interface{}) int64
+}
+
+// Validate is used to validate the quota schema.
+func (s *QuotaSchema) Validate() error {
+	if s.MaxRows < 0 {
+		return fmt.Errorf("max rows must not be negative")
+	}
+	if s.MaxBytes < 0 {
+		return fmt.Errorf("max bytes must not be negative")
+	}
+	return nil
+}
+
+// QuotaError is returned by Txn.Insert when an object doesn't fit in a
+// quota. It wraps ErrQuotaExceeded.
+type QuotaError struct {
+	// Table is the table the object was inserted into.
+	Table string
+
+	// Limit is the exceeded limit: "rows" or "bytes" for the quota of the
+	// table, or "budget" for the memory budget of the database.
+	Limit string
+
+	// Max is the value of the limit, and Usage is what the usage would have
+	// been after the insert.
+	Max   int64
+	Usage int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota %q of table %q exceeded: %d > %d", e.Limit, e.Table, e.Usage, e.Max)
+}
+
+// Unwrap returns ErrQuotaExceeded.
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// WithMemoryBudget limits the sum of the approximate sizes of the objects
+// of every table, see QuotaSchema.Size. Writers of disjoint tables started
+// with WriteTxn see the usage of the other tables as of the start of their
+// transaction, so the budget is checked again when they commit, and
+// Txn.CommitErr aborts a transaction that no longer fits.
+func WithMemoryBudget(maxBytes int64) Option {
+	return func(db *MemDB) {
+		db.budget = maxBytes
+	}
+}
+
+// TableUsage is the usage of a table, see Txn.Usage.
+type TableUsage struct {
+	Rows int
+
+	// Bytes is the sum of the approximate sizes of the objects of the table.
+	// It is only tracked for tables with a QuotaSchema setting MaxBytes or
+	// Size, or when the database has a memory budget, and is zero otherwise.
+	Bytes int64
+}
+
+// tracksUsage returns whether the usage of a table is kept in its usage
+// index.
+func (db *MemDB) tracksUsage(tableSchema *TableSchema) bool {
+	return tableSchema.Quota != nil || tableSchema.LRU != nil || db.budget > 0
+}
+
+// tracksBytes returns whether the sizes of the objects of a table are
+// tracked.
+func (db *MemDB) tracksBytes(tableSchema *TableSchema) bool {
+	quota := tableSchema.Quota
+	return db.budget > 0 || (quota != nil && (quota.MaxBytes > 0 || quota.Size != nil))
+}
+
+// objectSize returns the size of an object of a table, or zero if sizes
+// aren't tracked for the table.
+func (db *MemDB) objectSize(tableSchema *TableSchema, obj interface{}) int64 {
+	if obj == nil || !db.tracksBytes(tableSchema) {
+		return 0
+	}
+	if tableSchema.Quota != nil && tableSchema.Quota.Size != nil {
+		return tableSchema.Quota.Size(obj)
+	}
+	return EstimateSize(obj)
+}
+
+// Usage returns the number of rows and the approximate size of the objects
+// of a table, as seen by this transaction.
+func (txn *Txn) Usage(table string) (TableUsage, error) {
+	tableSchema, ok := txn.db.schema.Tables[table]
+	if !ok {
+		return TableUsage{}, fmt.Errorf("invalid table '%s'", table)
+	}
+	if txn.db.tracksUsage(tableSchema) {
+		txn.recordRead(table, usageIndex, usageKey)
+		return txn.usage(table), nil
+	}
+
+	txn.recordRead(table, id, nil)
+	rows := txn.readableIndex(table, id).CommitOnly().Len()
+	return TableUsage{Rows: rows}, nil
+}
+
+// usage returns the tracked usage of a table, without recording a read.
+// Optimistic transactions replay their writes at commit, so the quotas are
+// enforced against the current usage anyway.
+func (txn *Txn) usage(table string) TableUsage {
+	var raw interface{}
+	if exist, ok := txn.modified[tableIndex{table, usageIndex}]; ok {
+		raw, _ = exist.Get(usageKey)
+	} else {
+		tree, _ := txn.rootTxn.Get(indexPath(table, usageIndex))
+		raw, _ = tree.(*iradix.Tree).Get(usageKey)
+	}
+	usage, _ := raw.(TableUsage)
+	return usage
+}
+
+// insertUsage returns the usage of a table after evicting the given rows
+// and inserting obj in place of existing, which is nil on a create, and
+// enforces the quotas of the table and the memory budget. It returns false
+// if the usage isn't tracked.
+func (txn *Txn) insertUsage(tableSchema *TableSchema, existing, obj interface{}, evicted []interface{}) (TableUsage, bool, error) {
+	db := txn.db
+	if !db.tracksUsage(tableSchema) {
+		return TableUsage{}, false, nil
+	}
+
+	table := tableSchema.Name
+	usage := txn.usage(table)
+	if existing == nil {
+		usage.Rows++
+	}
+	delta := db.objectSize(tableSchema, obj) - db.objectSize(tableSchema, existing)
+	for _, row := range evicted {
+		usage.Rows--
+		delta -= db.objectSize(tableSchema, row)
+	}
+	usage.Bytes += delta
+	if txn.replica {
+		return usage, true, nil
+	}
+
+	if quota := tableSchema.Quota; quota != nil {
+		if quota.MaxRows > 0 && existing == nil && usage.Rows > quota.MaxRows {
+			return usage, true, &QuotaError{Table: table, Limit: "rows", Max: int64(quota.MaxRows), Usage: int64(usage.Rows)}
+		}
+		if quota.MaxBytes > 0 && delta > 0 && usage.Bytes > quota.MaxBytes {
+			return usage, true, &QuotaError{Table: table, Limit: "bytes", Max: quota.MaxBytes, Usage: usage.Bytes}
+		}
+	}
+	if db.budget > 0 && delta > 0 {
+		if total := txn.usageBytes() + delta; total > db.budget {
+			return usage, true, &QuotaError{Table: table, Limit: "budget", Max: db.budget, Usage: total}
+		}
+	}
+	return usage, true, nil
+}
+
+// checkBudget verifies that the objects of the transaction still fit in the
+// memory budget along with the ones committed by writers of other tables
+// since it started. It must be called with the commit lock held. Only
+// transactions growing the usage can fail.
+func (txn *Txn) checkBudget() error {
+	db := txn.db
+	if db.budget <= 0 {
+		return nil
+	}
+
+	var total, grown, largest int64
+	var table string
+	root := db.getRoot()
+	for name := range db.schema.Tables {
+		tree, _ := root.Get(indexPath(name, usageIndex))
+		raw, _ := tree.(*iradix.Tree).Get(usageKey)
+		current, _ := raw.(TableUsage)
+		total += current.Bytes
+
+		// The tables written by the transaction are locked, so their
+		// committed usage didn't change since it started
+		if _, ok := txn.modified[tableIndex{name, usageIndex}]; ok {
+			delta := txn.usage(name).Bytes - current.Bytes
+			grown += delta
+			if delta > largest {
+				largest, table = delta, name
+			}
+		}
+	}
+	if grown <= 0 || total+grown <= db.budget {
+		return nil
+	}
+	return &QuotaError{Table: table, Limit: "budget", Max: db.budget, Usage: total + grown}
+}
+
+// usageBytes returns the sum of the tracked sizes of the objects of every
+// table. It is computed once per transaction, and then kept up to date by
+// setUsage.
+func (txn *Txn) usageBytes() int64 {
+	if !txn.hasTotalBytes {
+		txn.totalBytes = 0
+		for name := range txn.db.schema.Tables {
+			txn.totalBytes += txn.usage(name).Bytes
+		}
+		txn.hasTotalBytes = true
+	}
+	return txn.totalBytes
+}
+
+// setUsage stores the usage of a table.
+func (txn *Txn) setUsage(table string, usage TableUsage) {
+	if txn.hasTotalBytes {
+		txn.totalBytes += usage.Bytes - txn.usage(table).Bytes
+	}
+	txn.writableIndex(table, usageIndex).Insert(usageKey, usage)
+}
+
+// removeUsage accounts for a deleted row in the usage of its table.
+func (txn *Txn) removeUsage(tableSchema *TableSchema, existing interface{}) {
+	if !txn.db.tracksUsage(tableSchema) {
+		return
+	}
+	usage := txn.usage(tableSchema.Name)
+	usage.Rows--
+	usage.Bytes -= txn.db.objectSize(tableSchema, existing)
+	txn.setUsage(tableSchema.Name, usage)
+}
+
+// EstimateSize returns the approximate number of bytes of memory used by an
+// object, including the memory it references through pointers, slices,
+// strings, maps and interfaces. Memory referenced several times is counted
+// once per pointer or slice, but strings are counted every time. Channels
+// and functions are counted as pointers.
+func EstimateSize(obj interface{}) int64 {
+	if obj == nil {
+		return 0
+	}
+	v := reflect.ValueOf(obj)
+	seen := make(map[uintptr]struct{})
+	return int64(v.Type().Size()) + estimateReferenced(v, seen)
+}
+
+// estimateReferenced returns the approximate size of the memory referenced
+// by a value, excluding the value itself. seen holds the addresses already
+// counted, so that cycles terminate.
+func estimateReferenced(v reflect.Value, seen map[uintptr]struct{}) int64 {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+
+	case reflect.Pointer:
+		if v.IsNil() || markSeen(v.Pointer(), seen) {
+			return 0
+		}
+		elem := v.Elem()
+		return int64(elem.Type().Size()) + estimateReferenced(elem, seen)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		elem := v.Elem()
+		size := estimateReferenced(elem, seen)
+		switch elem.Kind() {
+		case reflect.Pointer, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			// Stored in the interface directly
+		default:
+			size += int64(elem.Type().Size())
+		}
+		return size
+
+	case reflect.Slice:
+		if v.IsNil() || markSeen(v.Pointer(), seen) {
+			return 0
+		}
+		size := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		if hasReferences(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				size += estimateReferenced(v.Index(i), seen)
+			}
+		}
+		return size
+
+	case reflect.Array:
+		var size int64
+		if hasReferences(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				size += estimateReferenced(v.Index(i), seen)
+			}
+		}
+		return size
+
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += estimateReferenced(v.Field(i), seen)
+		}
+		return size
+
+	case reflect.Map:
+		if v.IsNil() || markSeen(v.Pointer(), seen) {
+			return 0
+		}
+		typ := v.Type()
+		size := int64(v.Len()) * int64(typ.Key().Size()+typ.Elem().Size())
+		if hasReferences(typ.Key()) || hasReferences(typ.Elem()) {
+			iter := v.MapRange()
+			for iter.Next() {
+				size += estimateReferenced(iter.Key(), seen)
+				size += estimateReferenced(iter.Value(), seen)
+			}
+		}
+		return size
+	}
+	return 0
+}
+
+// markSeen records an address counted by estimateReferenced and returns
+// whether it was already counted.
+func markSeen(ptr uintptr, seen map[uintptr]struct{}) bool {
+	if _, ok := seen[ptr]; ok {
+		return true
+	}
+	seen[ptr] = struct{}{}
+	return false
+}
+
+// hasReferences returns whether values of a type may reference memory
+// counted by estimateReferenced.
+func hasReferences(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.String, reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	case reflect.Array:
+		return hasReferences(typ.Elem())
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			if hasReferences(typ.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"errors"
+	"testing"
+)
+
+// testQuotaSize sizes objects by the length of their Foo field.
+func testQuotaSize(obj interface{}) int64 {
+	return int64(len(obj.(*TestObject).Foo))
+}
+
+func testQuotaDB(t *testing.T, quota *QuotaSchema, opts ...Option) *MemDB {
+	t.Helper()
+	schema := testPreCommitSchema()
+	schema.Tables["main"].Quota = quota
+	db, err := NewMemDB(schema, opts...)
+	noErr(t, err)
+	return db
+}
+
+func assertQuotaError(t *testing.T, err error, limit string, max, usage int64) {
+	t.Helper()
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected quota error, got: %v", err)
+	}
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("expected *QuotaError, got: %T", err)
+	}
+	if quotaErr.Limit != limit || quotaErr.Max != max || quotaErr.Usage != usage {
+		t.Fatalf("bad quota error: %#v", quotaErr)
+	}
+}
+
+func assertUsage(t *testing.T, txn *Txn, table string, rows int, bytes int64) {
+	t.Helper()
+	usage, err := txn.Usage(table)
+	noErr(t, err)
+	if usage.Rows != rows || usage.Bytes != bytes {
+		t.Fatalf("bad usage of %q: %#v", table, usage)
+	}
+}
+
+func TestTxn_Insert_QuotaRows(t *testing.T) {
+	db := testQuotaDB(t, &QuotaSchema{MaxRows: 2})
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "1")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "1")))
+	assertQuotaError(t, txn.Insert("main", testSavepointObj("c", "1")), "rows", 2, 3)
+
+	// Updates don't add rows
+	noErr(t, txn.Insert("main", testSavepointObj("a", "2")))
+	assertUsage(t, txn, "main", 2, 0)
+	txn.Commit()
+
+	txn = db.Txn(true)
+	noErr(t, txn.Delete("main", testSavepointObj("b", "1")))
+	noErr(t, txn.Insert("main", testSavepointObj("c", "1")))
+	assertQuotaError(t, txn.Insert("main", testSavepointObj("d", "1")), "rows", 2, 3)
+	txn.Commit()
+
+	assertUsage(t, db.Txn(false), "main", 2, 0)
+	assertExists(t, db.Txn(false), map[string]bool{"a": true, "b": false, "c": true})
+}
+
+func TestTxn_Insert_QuotaBytes(t *testing.T) {
+	db := testQuotaDB(t, &QuotaSchema{MaxBytes: 10, Size: testQuotaSize})
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "12345")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "1234")))
+	assertQuotaError(t, txn.Insert("main", testSavepointObj("c", "12")), "bytes", 10, 11)
+	assertQuotaError(t, txn.Insert("main", testSavepointObj("b", "123456")), "bytes", 10, 11)
+
+	// Shrinking a row is always allowed
+	noErr(t, txn.Insert("main", testSavepointObj("a", "1")))
+	assertUsage(t, txn, "main", 2, 5)
+
+	// Rolling back restores the usage
+	sp := txn.Savepoint()
+	noErr(t, txn.Insert("main", testSavepointObj("c", "12345")))
+	assertUsage(t, txn, "main", 3, 10)
+	noErr(t, txn.RollbackTo(sp))
+	assertUsage(t, txn, "main", 2, 5)
+	txn.Commit()
+
+	// Aborted transactions don't change the usage
+	txn = db.Txn(true)
+	noErr(t, txn.Delete("main", testSavepointObj("b", "1234")))
+	assertUsage(t, txn, "main", 1, 1)
+	txn.Abort()
+	assertUsage(t, db.Txn(false), "main", 2, 5)
+
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", &TestObject{ID: "c", Foo: "1234", Qux: []string{"x"}}))
+	deleted, err := txn.DeletePrefix("main", "id_prefix", "")
+	noErr(t, err)
+	if !deleted {
+		t.Fatalf("expected rows to be deleted")
+	}
+	assertUsage(t, txn, "main", 0, 0)
+	txn.Commit()
+	assertUsage(t, db.Txn(false), "main", 0, 0)
+}
+
+func TestMemDB_MemoryBudget(t *testing.T) {
+	// The budget covers every table, sizing the objects of tables without
+	// a size function with EstimateSize
+	other := &TestObject{ID: "b", Foo: "x"}
+	size := EstimateSize(other)
+	budget := 6 + size
+	db := testQuotaDB(t, &QuotaSchema{Size: testQuotaSize}, WithMemoryBudget(budget))
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "1234567")))
+	assertQuotaError(t, txn.Insert("other", other), "budget", budget, budget+1)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "123456")))
+	noErr(t, txn.Insert("other", other))
+	assertUsage(t, txn, "other", 1, size)
+	txn.Commit()
+
+	// Snapshots keep the budget
+	snap := db.Snapshot()
+	txn = snap.Txn(true)
+	assertQuotaError(t, txn.Insert("main", testSavepointObj("a", "1234567890")), "budget", budget, budget+4)
+	txn.Abort()
+}
+
+func TestMemDB_MemoryBudget_Commit(t *testing.T) {
+	other := &TestObject{ID: "b", Foo: "x"}
+	budget := 6 + EstimateSize(other)
+	db := testQuotaDB(t, &QuotaSchema{Size: testQuotaSize}, WithMemoryBudget(budget))
+
+	// Writers of disjoint tables each fit in the budget on their own
+	mainTxn, err := db.WriteTxn("main")
+	noErr(t, err)
+	noErr(t, mainTxn.Insert("main", testSavepointObj("a", "1234567")))
+	otherTxn, err := db.WriteTxn("other")
+	noErr(t, err)
+	noErr(t, otherTxn.Insert("other", other))
+
+	// The last one to commit doesn't fit anymore and is aborted
+	noErr(t, mainTxn.CommitErr())
+	assertQuotaError(t, otherTxn.CommitErr(), "budget", budget, budget+1)
+	assertUsage(t, db.Txn(false), "other", 0, 0)
+	otherTxn, err = db.WriteTxn("other")
+	noErr(t, err)
+	otherTxn.Abort()
+
+	// Writers shrinking the usage still commit
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "123456")))
+	noErr(t, txn.CommitErr())
+}
+
+func TestMemDB_MemoryBudget_Savepoint(t *testing.T) {
+	db := testQuotaDB(t, &QuotaSchema{Size: testQuotaSize}, WithMemoryBudget(6))
+
+	// The total usage of the transaction is rolled back with its rows
+	txn := db.Txn(true)
+	defer txn.Abort()
+	noErr(t, txn.Insert("main", testSavepointObj("a", "123")))
+	sp := txn.Savepoint()
+	noErr(t, txn.Insert("main", testSavepointObj("b", "123")))
+	assertQuotaError(t, txn.Insert("main", testSavepointObj("c", "1")), "budget", 6, 7)
+	noErr(t, txn.RollbackTo(sp))
+	noErr(t, txn.Insert("main", testSavepointObj("c", "12")))
+	assertQuotaError(t, txn.Insert("main", testSavepointObj("d", "12")), "budget", 6, 7)
+}
+
+func TestTxn_Usage(t *testing.T) {
+	db := testQuotaDB(t, nil)
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "1")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "1")))
+	assertUsage(t, txn, "main", 2, 0)
+	assertUsage(t, txn, "other", 0, 0)
+	txn.Commit()
+	assertUsage(t, db.Txn(false), "main", 2, 0)
+
+	if _, err := db.Txn(false).Usage("nope"); err == nil {
+		t.Fatalf("expected error for invalid table")
+	}
+}
+
+func TestBulkLoad_Usage(t *testing.T) {
+	db := testQuotaDB(t, &QuotaSchema{MaxRows: 2, Size: testQuotaSize})
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "12")))
+	txn.Commit()
+
+	// Quotas aren't enforced by BulkLoad, but the usage is kept up to date
+	noErr(t, db.BulkLoad("main", []interface{}{
+		testSavepointObj("a", "1"),
+		testSavepointObj("b", "123"),
+		testSavepointObj("c", "1"),
+	}))
+	assertUsage(t, db.Txn(false), "main", 3, 5)
+
+	txn = db.Txn(true)
+	assertQuotaError(t, txn.Insert("main", testSavepointObj("d", "1")), "rows", 2, 4)
+	txn.Abort()
+}
+
+func TestTxn_Apply_Quota(t *testing.T) {
+	db := testQuotaDB(t, &QuotaSchema{MaxRows: 1})
+
+	changes := Changes{
+		{Table: "main", After: testSavepointObj("a", "1")},
+		{Table: "main", After: testSavepointObj("b", "1")},
+	}
+	txn := db.Txn(true)
+	defer txn.Abort()
+	assertQuotaError(t, txn.Apply(changes), "rows", 1, 2)
+}
+
+func TestQuotaSchema_Validate(t *testing.T) {
+	for _, quota := range []*QuotaSchema{{MaxRows: -1}, {MaxBytes: -1}} {
+		schema := testValidSchema()
+		schema.Tables["main"].Quota = quota
+		if err := schema.Validate(); err == nil {
+			t.Fatalf("expected error for %#v", quota)
+		}
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+		Tags map[string]string
+		Any  interface{}
+	}
+
+	if size := EstimateSize(nil); size != 0 {
+		t.Fatalf("bad size of nil: %d", size)
+	}
+
+	empty := EstimateSize(&node{})
+	named := EstimateSize(&node{Name: "12345"})
+	if named != empty+5 {
+		t.Fatalf("bad size: %d, empty: %d", named, empty)
+	}
+
+	// Cycles terminate and shared pointers are counted once
+	a := &node{Name: "a"}
+	a.Next = a
+	if size := EstimateSize(a); size != empty+1 {
+		t.Fatalf("bad size of cycle: %d", size)
+	}
+
+	tagged := EstimateSize(&node{Tags: map[string]string{"k": "v"}, Any: 42})
+	if tagged <= empty+2 {
+		t.Fatalf("bad size with references: %d, empty: %d", tagged, empty)
+	}
+
+	if size := EstimateSize(make([]byte, 0, 100)); size < 100 {
+		t.Fatalf("bad size of slice: %d", size)
+	}
+}
@@ -0,0 +1,512 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+// The replication protocol starts with a hello message from the follower,
+// holding the ID of the leader it last synced from and the commit index of
+// the leader it reached. The leader then sends frames, each made of a type,
+// the commit index of the leader and a length-prefixed payload. A snapshot
+// is sent as a snapshot frame holding the ID of the leader, rows frames each
+// holding the binary encoding of some rows of the database, and an empty
+// snapshot end frame. A changes frame holds the binary encoding of the
+// changes of one commit.
+const (
+	helloSize       = 24
+	frameHeaderSize = 13
+
+	frameSnapshot    byte = 1
+	frameChanges     byte = 2
+	frameRows        byte = 3
+	frameSnapshotEnd byte = 4
+
+	// snapshotChunkSize is the approximate size of the rows sent in each
+	// rows frame, as estimated by EstimateSize.
+	snapshotChunkSize = 1 << 20
+)
+
+const (
+	// followerMinBackoff and followerMaxBackoff bound the wait between two
+	// connection attempts of a follower.
+	followerMinBackoff = 50 * time.Millisecond
+	followerMaxBackoff = 5 * time.Second
+)
+
+// DefaultMaxFrameSize is the size of the largest frame payload a follower
+// accepts when Follower.MaxFrameSize isn't set.
+const DefaultMaxFrameSize = 1 << 30
+
+// ServeReplica streams the content of the database to a follower connected
+// through conn, see Follower. The follower first gets a snapshot of the
+// database unless it can catch up from the events kept for replay, then the
+// changes of every commit. It returns once the follower disconnects or the
+// context is done, and closes conn.
+//
+// Events must be enabled, see WithEvents. Changes are encoded with the given
+// registry, which must hold the types of every table.
+func (db *MemDB) ServeReplica(ctx context.Context, conn io.ReadWriteCloser, codec *CodecRegistry) error {
+	if db.events == nil {
+		return fmt.Errorf("events are not enabled")
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var hello [helloSize]byte
+	if _, err := io.ReadFull(conn, hello[:]); err != nil {
+		return err
+	}
+
+	// The follower doesn't send anything after the hello, so reading only
+	// detects that it went away.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go func() {
+		_, err := io.Copy(io.Discard, conn)
+		if err == nil {
+			err = io.EOF
+		}
+		cancel(err)
+	}()
+
+	index := binary.BigEndian.Uint64(hello[16:])
+	resync := !bytes.Equal(hello[:16], db.events.id[:])
+	for {
+		var sub *Subscription
+		var err error
+		if resync {
+			if sub, index, err = db.sendSnapshot(ctx, conn, codec); err != nil {
+				if cause := context.Cause(ctx); cause != nil {
+					return cause
+				}
+				return err
+			}
+		} else if sub, err = db.Subscribe(ctx, nil, ResumeAfter(index)); err != nil {
+			// The follower is ahead of the leader
+			resync = true
+			continue
+		}
+		resync, index, err = db.sendChanges(conn, codec, sub, index)
+		sub.Close()
+		if err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				return cause
+			}
+			return err
+		}
+	}
+}
+
+// sendSnapshot sends the whole content of the database in chunks, and
+// returns its commit index along with a subscription to the following
+// commits.
+func (db *MemDB) sendSnapshot(ctx context.Context, w io.Writer, codec *CodecRegistry) (*Subscription, uint64, error) {
+	// Commits publish their events before releasing the lock, so the
+	// subscription gets every commit following the root however long the
+	// snapshot takes to send.
+	db.commitLock.Lock()
+	root := db.getRoot()
+	index := rootCommitIndex(root)
+	sub, err := db.Subscribe(ctx, nil, ResumeAfter(index))
+	db.commitLock.Unlock()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := db.sendRows(w, codec, root, index); err != nil {
+		sub.Close()
+		return nil, 0, err
+	}
+	return sub, index, nil
+}
+
+// sendRows sends the snapshot frames of the rows of a root.
+func (db *MemDB) sendRows(w io.Writer, codec *CodecRegistry, root *iradix.Tree, index uint64) error {
+	leader := db.events.id
+	if err := writeFrame(w, frameSnapshot, index, leader[:]); err != nil {
+		return err
+	}
+
+	tables := make([]string, 0, len(db.schema.Tables))
+	for table := range db.schema.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var changes Changes
+	var size int64
+	flush := func() error {
+		payload, err := codec.EncodeBinary(changes)
+		if err != nil {
+			return err
+		}
+		changes, size = changes[:0], 0
+		return writeFrame(w, frameRows, index, payload)
+	}
+	for _, table := range tables {
+		raw, _ := root.Get(indexPath(table, id))
+		iter := raw.(*iradix.Tree).Root().Iterator()
+		for key, obj, ok := iter.Next(); ok; key, obj, ok = iter.Next() {
+			changes = append(changes, Change{Table: table, After: obj, primaryKey: key})
+			if size += EstimateSize(obj); size < snapshotChunkSize {
+				continue
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(changes) > 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	return writeFrame(w, frameSnapshotEnd, index, nil)
+}
+
+// sendChanges sends the events of a subscription until it is reset, in
+// which case resync is set, or fails. It returns the last sent index.
+func (db *MemDB) sendChanges(w io.Writer, codec *CodecRegistry, sub *Subscription, index uint64) (bool, uint64, error) {
+	for {
+		event, err := sub.Next()
+		if err != nil {
+			return false, index, err
+		}
+		if event.Reset {
+			return true, index, nil
+		}
+		payload, err := codec.EncodeBinary(event.Changes)
+		if err != nil {
+			return false, index, err
+		}
+		if err := writeFrame(w, frameChanges, event.Index, payload); err != nil {
+			return false, index, err
+		}
+		index = event.Index
+	}
+}
+
+// writeFrame writes a frame of the replication protocol.
+func writeFrame(w io.Writer, typ byte, index uint64, payload []byte) error {
+	if uint64(len(payload)) > math.MaxUint32 {
+		return fmt.Errorf("frame payload of %d bytes exceeds the maximum of %d bytes", len(payload), uint64(math.MaxUint32))
+	}
+
+	var header [frameHeaderSize]byte
+	header[0] = typ
+	binary.BigEndian.PutUint64(header[1:], index)
+	binary.BigEndian.PutUint32(header[9:], uint32(len(payload)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readFrame reads a frame of the replication protocol, failing if its
+// payload is larger than maxSize.
+func readFrame(r io.Reader, maxSize int) (byte, uint64, []byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[9:])
+	if uint64(size) > uint64(maxSize) {
+		return 0, 0, nil, fmt.Errorf("frame payload of %d bytes exceeds the maximum of %d bytes", size, maxSize)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	return header[0], binary.BigEndian.Uint64(header[1:]), payload, nil
+}
+
+// Follower keeps a database in sync with a leader database, served with
+// ServeReplica. Each commit of the leader is applied in a single write
+// transaction, so readers of the follower see the same transaction
+// boundaries and watches fire as usual.
+//
+// The follower database must have the same schema as the leader, and must
+// not be written to by anything else. Validators, foreign keys and
+// pre-commit hooks aren't run on the follower, since the leader already
+// enforced them.
+type Follower struct {
+	// MaxFrameSize is the size of the largest frame payload accepted from
+	// the leader, DefaultMaxFrameSize if zero. Snapshots are sent in chunks
+	// of about a megabyte, but the changes of a commit are sent in a single
+	// frame, so it must be larger than the encoding of the largest commit.
+	// It must be set before the follower starts syncing.
+	MaxFrameSize int
+
+	db    *MemDB
+	codec *CodecRegistry
+
+	l       sync.Mutex
+	leader  [16]byte
+	index   uint64
+	applied chan struct{}
+}
+
+// NewFollower returns a follower applying the changes of a leader to db.
+// Changes are decoded with the given registry, which must hold the types of
+// every table.
+func NewFollower(db *MemDB, codec *CodecRegistry) *Follower {
+	return &Follower{
+		db:      db,
+		codec:   codec,
+		applied: make(chan struct{}),
+	}
+}
+
+// Index returns the commit index of the leader that the follower reached.
+// It isn't related to the commit index of the follower database.
+func (f *Follower) Index() uint64 {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return f.index
+}
+
+// WaitForIndex waits until the follower reached at least the given commit
+// index of the leader, or the context is done.
+func (f *Follower) WaitForIndex(ctx context.Context, index uint64) error {
+	for {
+		f.l.Lock()
+		reached, applied := f.index >= index, f.applied
+		f.l.Unlock()
+		if reached {
+			return nil
+		}
+
+		select {
+		case <-applied:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Run keeps the follower in sync, connecting to the leader with dial and
+// reconnecting with a backoff whenever the connection fails. The follower
+// catches up from the commit index it reached. Run returns the context
+// error once the context is done.
+func (f *Follower) Run(ctx context.Context, dial func(ctx context.Context) (io.ReadWriteCloser, error)) error {
+	backoff := followerMinBackoff
+	for {
+		conn, err := dial(ctx)
+		if err == nil {
+			synced := f.Index()
+			_ = f.Sync(ctx, conn)
+			if f.Index() != synced {
+				backoff = followerMinBackoff
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, followerMaxBackoff)
+	}
+}
+
+// Sync keeps the follower in sync over a single connection to the leader.
+// It returns once the connection fails or the context is done, and closes
+// conn.
+func (f *Follower) Sync(ctx context.Context, conn io.ReadWriteCloser) error {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	maxFrameSize := f.MaxFrameSize
+	if maxFrameSize == 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+
+	f.l.Lock()
+	var hello [helloSize]byte
+	copy(hello[:16], f.leader[:])
+	binary.BigEndian.PutUint64(hello[16:], f.index)
+	f.l.Unlock()
+	if _, err := conn.Write(hello[:]); err != nil {
+		return f.syncErr(ctx, err)
+	}
+
+	// A snapshot is applied in a single transaction, committed once all
+	// its rows are received
+	var snap *followerSnapshot
+	defer func() {
+		if snap != nil {
+			snap.txn.Abort()
+		}
+	}()
+
+	for {
+		typ, index, payload, err := readFrame(conn, maxFrameSize)
+		if err != nil {
+			return f.syncErr(ctx, err)
+		}
+
+		switch {
+		case typ == frameSnapshot && snap == nil:
+			if len(payload) != 16 {
+				return fmt.Errorf("invalid snapshot frame")
+			}
+			snap = f.beginSnapshot(index)
+			copy(snap.leader[:], payload)
+		case typ == frameRows && snap != nil:
+			err = snap.apply(payload)
+		case typ == frameSnapshotEnd && snap != nil:
+			err = f.finishSnapshot(snap)
+			snap = nil
+		case typ == frameChanges && snap == nil:
+			err = f.applyChanges(index, payload)
+		default:
+			err = fmt.Errorf("unexpected frame type %d", typ)
+		}
+		if err != nil {
+			// Start over from a snapshot on the next connection
+			f.l.Lock()
+			f.leader = [16]byte{}
+			f.l.Unlock()
+			return err
+		}
+	}
+}
+
+// syncErr returns the context error instead of the error of a connection
+// closed because the context is done.
+func (f *Follower) syncErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// followerSnapshot is a snapshot of the leader being received. It replaces
+// the content of the database, and rows that didn't change aren't written,
+// so their watches don't fire.
+type followerSnapshot struct {
+	f      *Follower
+	leader [16]byte
+	index  uint64
+	txn    *Txn
+
+	// keep holds the rows received, the others are deleted once the
+	// snapshot is complete.
+	keep map[objectID]struct{}
+}
+
+// beginSnapshot starts receiving a snapshot of the leader at the given
+// commit index.
+func (f *Follower) beginSnapshot(index uint64) *followerSnapshot {
+	txn := f.db.Txn(true)
+	txn.replica = true
+	return &followerSnapshot{
+		f:     f,
+		index: index,
+		txn:   txn,
+		keep:  make(map[objectID]struct{}),
+	}
+}
+
+// apply writes the rows of a rows frame.
+func (s *followerSnapshot) apply(payload []byte) error {
+	changes, err := s.f.codec.DecodeBinary(payload)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if _, ok := s.f.db.schema.Tables[change.Table]; !ok {
+			return fmt.Errorf("invalid table '%s'", change.Table)
+		}
+		s.keep[objectID{change.Table, string(change.primaryKey)}] = struct{}{}
+		existing, _ := s.txn.writableIndex(change.Table, id).Get(change.primaryKey)
+		if existing != nil && reflect.DeepEqual(existing, change.After) {
+			continue
+		}
+		if err := s.txn.Insert(change.Table, change.After); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishSnapshot deletes the rows missing from a complete snapshot and
+// commits it.
+func (f *Follower) finishSnapshot(s *followerSnapshot) error {
+	defer s.txn.Abort()
+	for table := range f.db.schema.Tables {
+		var stale []interface{}
+		s.txn.writableIndex(table, id).Root().Walk(func(key []byte, obj interface{}) bool {
+			if _, ok := s.keep[objectID{table, string(key)}]; !ok {
+				stale = append(stale, obj)
+			}
+			return false
+		})
+		for _, obj := range stale {
+			if err := s.txn.Delete(table, obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.txn.Commit()
+	f.advance(s.leader, s.index)
+	return nil
+}
+
+// applyChanges applies the changes of a commit of the leader.
+func (f *Follower) applyChanges(index uint64, payload []byte) error {
+	changes, err := f.codec.DecodeBinary(payload)
+	if err != nil {
+		return err
+	}
+
+	txn := f.db.Txn(true)
+	defer txn.Abort()
+	txn.replica = true
+	for _, change := range changes {
+		if change.After != nil {
+			err = txn.Insert(change.Table, change.After)
+		} else {
+			err = txn.Delete(change.Table, change.Before)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	txn.Commit()
+	f.l.Lock()
+	leader := f.leader
+	f.l.Unlock()
+	f.advance(leader, index)
+	return nil
+}
+
+// advance records the commit index of the leader reached by the follower
+// and wakes up the waiters.
+func (f *Follower) advance(leader [16]byte, index uint64) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	f.leader = leader
+	f.index = index
+	close(f.applied)
+	f.applied = make(chan struct{})
+}
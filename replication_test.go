@@ -0,0 +1,387 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testReplica connects a follower to a leader over a pipe. The returned
+// function closes the connection and waits for both ends to stop. It is
+// also called when the test ends.
+func testReplica(t *testing.T, leader *MemDB, follower *Follower) func() {
+	t.Helper()
+	leaderConn, followerConn := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{}, 2)
+	go func() {
+		leader.ServeReplica(ctx, leaderConn, testCodecRegistry())
+		done <- struct{}{}
+	}()
+	go func() {
+		follower.Sync(ctx, followerConn)
+		done <- struct{}{}
+	}()
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			cancel()
+			<-done
+			<-done
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func testReplicaDBs(t *testing.T, replay int) (*MemDB, *MemDB, *Follower) {
+	t.Helper()
+	leader, err := NewMemDB(testValidSchema(), WithEvents(replay, 100))
+	noErr(t, err)
+	db := testDB(t)
+	return leader, db, NewFollower(db, testCodecRegistry())
+}
+
+func testReplicaWait(t *testing.T, leader *MemDB, follower *Follower) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	noErr(t, follower.WaitForIndex(ctx, leader.Txn(false).CommitIndex()))
+}
+
+func testReplicaRows(t *testing.T, db *MemDB) map[string]string {
+	t.Helper()
+	rows := make(map[string]string)
+	iter, err := db.Txn(false).Get("main", "id")
+	noErr(t, err)
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		rows[raw.(*TestObject).ID] = raw.(*TestObject).Foo
+	}
+	return rows
+}
+
+func testReplicaWrite(t *testing.T, db *MemDB, insert []*TestObject, remove ...*TestObject) {
+	t.Helper()
+	txn := db.Txn(true)
+	for _, obj := range insert {
+		noErr(t, txn.Insert("main", obj))
+	}
+	for _, obj := range remove {
+		noErr(t, txn.Delete("main", obj))
+	}
+	txn.Commit()
+}
+
+func TestReplication(t *testing.T) {
+	leader, db, follower := testReplicaDBs(t, 100)
+	testReplicaWrite(t, leader, []*TestObject{testSavepointObj("a", "x"), testSavepointObj("b", "x")})
+
+	testReplica(t, leader, follower)
+	testReplicaWait(t, leader, follower)
+	if rows := testReplicaRows(t, db); !reflect.DeepEqual(rows, map[string]string{"a": "x", "b": "x"}) {
+		t.Fatalf("bad: %v", rows)
+	}
+
+	watch, _, err := db.Txn(false).FirstWatch("main", "id", "a")
+	noErr(t, err)
+	start := db.Txn(false).CommitIndex()
+
+	// Each commit of the leader is applied in its own transaction
+	testReplicaWrite(t, leader, []*TestObject{testSavepointObj("a", "y"), testSavepointObj("c", "x")})
+	testReplicaWrite(t, leader, nil, testSavepointObj("b", "x"))
+	testReplicaWait(t, leader, follower)
+
+	if rows := testReplicaRows(t, db); !reflect.DeepEqual(rows, map[string]string{"a": "y", "c": "x"}) {
+		t.Fatalf("bad: %v", rows)
+	}
+	if index := db.Txn(false).CommitIndex(); index != start+2 {
+		t.Fatalf("bad: %d", index)
+	}
+	select {
+	case <-watch:
+	case <-time.After(time.Second):
+		t.Fatalf("watch should fire")
+	}
+}
+
+func TestReplication_CatchUp(t *testing.T) {
+	leader, db, follower := testReplicaDBs(t, 100)
+	testReplicaWrite(t, leader, []*TestObject{testSavepointObj("a", "x")})
+
+	stop := testReplica(t, leader, follower)
+	testReplicaWait(t, leader, follower)
+	stop()
+
+	testReplicaWrite(t, leader, []*TestObject{testSavepointObj("b", "x")})
+	testReplicaWrite(t, leader, nil, testSavepointObj("a", "x"))
+	start := db.Txn(false).CommitIndex()
+
+	// The follower resumes from the replayed events instead of a snapshot
+	testReplica(t, leader, follower)
+	testReplicaWait(t, leader, follower)
+	if rows := testReplicaRows(t, db); !reflect.DeepEqual(rows, map[string]string{"b": "x"}) {
+		t.Fatalf("bad: %v", rows)
+	}
+	if index := db.Txn(false).CommitIndex(); index != start+2 {
+		t.Fatalf("bad: %d", index)
+	}
+}
+
+func TestReplication_Resync(t *testing.T) {
+	leader, db, follower := testReplicaDBs(t, 1)
+	testReplicaWrite(t, leader, []*TestObject{testSavepointObj("a", "x"), testSavepointObj("b", "x")})
+
+	stop := testReplica(t, leader, follower)
+	testReplicaWait(t, leader, follower)
+	stop()
+
+	watch, _, err := db.Txn(false).FirstWatch("main", "id", "b")
+	noErr(t, err)
+
+	// Too many commits for the replay buffer, so a new snapshot is sent
+	testReplicaWrite(t, leader, []*TestObject{testSavepointObj("c", "x")})
+	testReplicaWrite(t, leader, nil, testSavepointObj("a", "x"))
+	start := db.Txn(false).CommitIndex()
+
+	testReplica(t, leader, follower)
+	testReplicaWait(t, leader, follower)
+	if rows := testReplicaRows(t, db); !reflect.DeepEqual(rows, map[string]string{"b": "x", "c": "x"}) {
+		t.Fatalf("bad: %v", rows)
+	}
+	if index := db.Txn(false).CommitIndex(); index != start+1 {
+		t.Fatalf("bad: %d", index)
+	}
+
+	// Rows that didn't change aren't rewritten
+	select {
+	case <-watch:
+		t.Fatalf("watch should not fire")
+	default:
+	}
+
+	// A follower of another leader starts over too, even though it is
+	// behind the commit index the follower reached
+	other, err := NewMemDB(testValidSchema(), WithEvents(100, 100))
+	noErr(t, err)
+	testReplicaWrite(t, other, []*TestObject{testSavepointObj("d", "x")})
+	stop()
+	testReplica(t, other, follower)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for follower.Index() != 1 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if rows := testReplicaRows(t, db); !reflect.DeepEqual(rows, map[string]string{"d": "x"}) {
+		t.Fatalf("bad: %v", rows)
+	}
+}
+
+func TestReplication_ForeignKeys(t *testing.T) {
+	leader, err := NewMemDB(testForeignKeySchema(Cascade), WithEvents(100, 100))
+	noErr(t, err)
+	txn := leader.Txn(true)
+	noErr(t, txn.Insert("nodes", &TestNode{ID: "node1", Name: "alpha"}))
+	noErr(t, txn.Insert("allocs", &TestAlloc{ID: "alloc1", NodeID: "node1"}))
+	txn.Commit()
+
+	db, err := NewMemDB(testForeignKeySchema(Cascade))
+	noErr(t, err)
+	codec := NewCodecRegistry()
+	codec.Register("nodes", &TestNode{})
+	codec.Register("allocs", &TestAlloc{})
+	follower := NewFollower(db, codec)
+
+	leaderConn, followerConn := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go leader.ServeReplica(ctx, leaderConn, codec)
+	go follower.Sync(ctx, followerConn)
+	testReplicaWait(t, leader, follower)
+
+	// The cascaded delete is replicated as is, without cascading again
+	txn = leader.Txn(true)
+	noErr(t, txn.Delete("nodes", &TestNode{ID: "node1"}))
+	txn.Commit()
+	testReplicaWait(t, leader, follower)
+
+	for _, table := range []string{"nodes", "allocs"} {
+		raw, err := db.Txn(false).First(table, "id")
+		noErr(t, err)
+		if raw != nil {
+			t.Fatalf("bad: %#v", raw)
+		}
+	}
+}
+
+func TestFollower_Run(t *testing.T) {
+	leader, db, follower := testReplicaDBs(t, 100)
+	testReplicaWrite(t, leader, []*TestObject{testSavepointObj("a", "x")})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conns := make(chan net.Conn, 10)
+	dial := func(ctx context.Context) (io.ReadWriteCloser, error) {
+		leaderConn, followerConn := net.Pipe()
+		go leader.ServeReplica(ctx, leaderConn, testCodecRegistry())
+		conns <- leaderConn
+		return followerConn, nil
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- follower.Run(ctx, dial) }()
+	testReplicaWait(t, leader, follower)
+
+	// Break the connection, the follower reconnects and catches up
+	(<-conns).Close()
+	testReplicaWrite(t, leader, []*TestObject{testSavepointObj("b", "x")})
+	testReplicaWait(t, leader, follower)
+	if rows := testReplicaRows(t, db); !reflect.DeepEqual(rows, map[string]string{"a": "x", "b": "x"}) {
+		t.Fatalf("bad: %v", rows)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != context.Canceled {
+			t.Fatalf("bad: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out")
+	}
+}
+
+func TestMemDB_ServeReplica_NoEvents(t *testing.T) {
+	leaderConn, followerConn := net.Pipe()
+	defer followerConn.Close()
+	if err := testDB(t).ServeReplica(context.Background(), leaderConn, testCodecRegistry()); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestFollower_MaxFrameSize(t *testing.T) {
+	leader, db, follower := testReplicaDBs(t, 100)
+	testReplicaWrite(t, leader, []*TestObject{testSavepointObj("a", "x")})
+
+	// The snapshot doesn't fit in a frame
+	follower.MaxFrameSize = 16
+	leaderConn, followerConn := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go leader.ServeReplica(ctx, leaderConn, testCodecRegistry())
+	err := follower.Sync(ctx, followerConn)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the maximum of 16 bytes") {
+		t.Fatalf("bad: %v", err)
+	}
+	if rows := testReplicaRows(t, db); len(rows) != 0 {
+		t.Fatalf("bad: %v", rows)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	var buf bytes.Buffer
+	noErr(t, writeFrame(&buf, frameChanges, 7, []byte("hello")))
+	frame := buf.Bytes()
+
+	typ, index, payload, err := readFrame(bytes.NewReader(frame), 5)
+	noErr(t, err)
+	if typ != frameChanges || index != 7 || string(payload) != "hello" {
+		t.Fatalf("bad: %d %d %q", typ, index, payload)
+	}
+	if _, _, _, err := readFrame(bytes.NewReader(frame), 4); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+// testSnapshotConn counts the snapshots sent by a leader, and calls
+// onSnapshot while the first one is being sent.
+type testSnapshotConn struct {
+	io.ReadWriteCloser
+	snapshots  atomic.Int32
+	onSnapshot func()
+}
+
+func (c *testSnapshotConn) Write(p []byte) (int, error) {
+	if len(p) == frameHeaderSize && p[0] == frameSnapshot && c.snapshots.Add(1) == 1 {
+		c.onSnapshot()
+	}
+	return c.ReadWriteCloser.Write(p)
+}
+
+func TestReplication_CommitDuringSnapshot(t *testing.T) {
+	// Only the last commit is kept for replay
+	leader, db, follower := testReplicaDBs(t, 1)
+	testReplicaWrite(t, leader, []*TestObject{testSavepointObj("a", "x")})
+
+	leaderConn, followerConn := net.Pipe()
+	conn := &testSnapshotConn{ReadWriteCloser: leaderConn}
+	conn.onSnapshot = func() {
+		for _, id := range []string{"b", "c", "d"} {
+			txn := leader.Txn(true)
+			_ = txn.Insert("main", testSavepointObj(id, "x"))
+			txn.Commit()
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go leader.ServeReplica(ctx, conn, testCodecRegistry())
+	go follower.Sync(ctx, followerConn)
+	waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer waitCancel()
+	noErr(t, follower.WaitForIndex(waitCtx, 4))
+
+	// The commits made while sending the snapshot follow it
+	if n := conn.snapshots.Load(); n != 1 {
+		t.Fatalf("bad snapshots: %d", n)
+	}
+	expected := map[string]string{"a": "x", "b": "x", "c": "x", "d": "x"}
+	if rows := testReplicaRows(t, db); !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("bad: %v", rows)
+	}
+}
+
+func TestReplication_SnapshotChunks(t *testing.T) {
+	leader, db, follower := testReplicaDBs(t, 100)
+	big := strings.Repeat("x", snapshotChunkSize/2)
+	testReplicaWrite(t, leader, []*TestObject{
+		{ID: "a", Foo: big, Qux: []string{"x"}},
+		{ID: "b", Foo: big, Qux: []string{"x"}},
+		{ID: "c", Foo: big, Qux: []string{"x"}},
+		{ID: "d", Foo: "x", Qux: []string{"x"}},
+	})
+
+	// The rows are split in frames of about snapshotChunkSize
+	var buf bytes.Buffer
+	noErr(t, leader.sendRows(&buf, testCodecRegistry(), leader.getRoot(), 1))
+	var types []byte
+	for buf.Len() > 0 {
+		typ, index, _, err := readFrame(&buf, DefaultMaxFrameSize)
+		noErr(t, err)
+		if index != 1 {
+			t.Fatalf("bad index: %d", index)
+		}
+		types = append(types, typ)
+	}
+	if !bytes.Equal(types, []byte{frameSnapshot, frameRows, frameRows, frameSnapshotEnd}) {
+		t.Fatalf("bad frames: %v", types)
+	}
+
+	// The follower accepts each frame, but not the whole snapshot at once
+	follower.MaxFrameSize = 5 * snapshotChunkSize / 4
+	testReplica(t, leader, follower)
+	testReplicaWait(t, leader, follower)
+	if rows := testReplicaRows(t, db); len(rows) != 4 || rows["c"] != big {
+		t.Fatalf("bad: %d rows", len(rows))
+	}
+}
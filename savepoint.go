@@ -0,0 +1,230 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"bytes"
+	"fmt"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+// Savepoint is a point within a write transaction that the transaction can
+// be rolled back to with RollbackTo.
+type Savepoint struct {
+	// modified holds clones of the modified index transactions at the time
+	// the savepoint was taken. They are never written to.
+	modified map[tableIndex]*iradix.Txn
+
+	// changes is the change log at the time the savepoint was taken. Its
+	// capacity is capped so that later appends never write into it.
+	changes Changes
+
+	// after is the number of deferred functions at the time the savepoint
+	// was taken.
+	after int
+
+	// ops is the number of logged writes of an optimistic transaction at
+	// the time the savepoint was taken.
+	ops int
+}
+
+// Savepoint records the current state of the write transaction so that
+// later modifications can be undone with RollbackTo, without aborting the
+// whole transaction. Savepoints nest: rolling back to a savepoint discards
+// the savepoints taken after it, but keeps the ones taken before it. Nil is
+// returned for read-only, aborted or committed transactions.
+//
+// Taking a savepoint is cheap since the index transactions are cloned
+// without copying the underlying radix trees. However, once the first
+// savepoint is taken, mutations are no longer tracked as they are written:
+// the watches to fire are found when the transaction is committed, by
+// comparing each modified index with its committed version.
+func (txn *Txn) Savepoint() *Savepoint {
+	if !txn.write || txn.rootTxn == nil {
+		return nil
+	}
+
+	// Stop tracking mutations in the index transactions so they can be
+	// replaced by a rollback without losing track of what to notify.
+	if !txn.untracked {
+		txn.untracked = true
+		for key, indexTxn := range txn.modified {
+			txn.modified[key] = indexTxn.Clone()
+		}
+	}
+
+	sp := &Savepoint{
+		modified: make(map[tableIndex]*iradix.Txn, len(txn.modified)),
+		after:    len(txn.after),
+	}
+	for key, indexTxn := range txn.modified {
+		sp.modified[key] = indexTxn.Clone()
+	}
+	if txn.changes != nil {
+		sp.changes = txn.changes[:len(txn.changes):len(txn.changes)]
+	}
+	if txn.optimistic != nil {
+		sp.ops = len(txn.optimistic.ops)
+	}
+
+	txn.savepoints = append(txn.savepoints, sp)
+	return sp
+}
+
+// replayMutations returns a transaction tracking the mutations turning the
+// original version of an index into its final version, replayed on the
+// original version. Only the nodes of the original version that aren't
+// kept in the final version are tracked, so notifying the transaction
+// doesn't close the channels of the committed nodes. The index transactions
+// can't track mutations themselves once a savepoint has been taken, since
+// the nodes they create are shared with their clones.
+func replayMutations(original, final *iradix.Tree) *iradix.Txn {
+	notifyTxn := original.Txn()
+	notifyTxn.TrackMutate(true)
+	changedKeys(original.Root(), final.Root(), nil, func(k []byte) {
+		if v, ok := final.Get(k); ok {
+			notifyTxn.Insert(k, v)
+		} else {
+			notifyTxn.Delete(k)
+		}
+	})
+	return notifyTxn
+}
+
+// changedKeys calls fn with a copy of each key under the given prefix that
+// was inserted, updated or deleted between two versions of a radix tree.
+func changedKeys(old, new *iradix.Node, prefix []byte, fn func(k []byte)) {
+	diffTrees(old, new, prefix, fn, nil)
+}
+
+// diffTrees compares two versions of a radix tree under the given prefix,
+// calling key with a copy of each key that was inserted, updated or deleted,
+// and watch with each watch channel of the old version that isn't part of
+// the new version, possibly more than once. Either function may be nil.
+// Nodes are compared by the identity of their watch channel, which is
+// replaced whenever a node is copied, so the subtrees shared by both versions
+// are skipped.
+func diffTrees(old, new *iradix.Node, prefix []byte, key func(k []byte), watch func(ch <-chan struct{})) {
+	oldIter, newIter := old.Iterator(), new.Iterator()
+	oldNode, newNode := oldIter.SeekPrefixWatch(prefix), newIter.SeekPrefixWatch(prefix)
+	if oldNode == newNode {
+		return
+	}
+	if watch != nil {
+		watch(oldNode)
+	}
+	oldKey, _, oldOK := oldIter.Next()
+	newKey, _, newOK := newIter.Next()
+	if !oldOK && !newOK {
+		return
+	}
+
+	// The key equal to the prefix is stored in the node itself, and its leaf
+	// also gets a new watch channel whenever it is written
+	if (oldOK && bytes.Equal(oldKey, prefix)) || (newOK && bytes.Equal(newKey, prefix)) {
+		oldWatch, _, oldOK := old.GetWatch(prefix)
+		newWatch, _, newOK := new.GetWatch(prefix)
+		if oldOK != newOK || oldWatch != newWatch {
+			if key != nil {
+				key(append([]byte(nil), prefix...))
+			}
+			if watch != nil && oldOK {
+				watch(oldWatch)
+			}
+		}
+	}
+
+	// Recurse into the children of both sides in order, by finding the
+	// smallest key following each child.
+	next := append(append([]byte(nil), prefix...), 0)
+	for {
+		label, ok := nextLabel(old, prefix, next)
+		if newLabel, newOK := nextLabel(new, prefix, next); newOK && (!ok || newLabel < label) {
+			label, ok = newLabel, true
+		}
+		if !ok {
+			return
+		}
+
+		next[len(prefix)] = label
+		diffTrees(old, new, next, key, watch)
+		if label == 0xff {
+			return
+		}
+		next[len(prefix)] = label + 1
+	}
+}
+
+// nextLabel returns the byte following the prefix in the smallest key of
+// the tree under the prefix that is greater than or equal to lower.
+func nextLabel(root *iradix.Node, prefix, lower []byte) (byte, bool) {
+	iter := root.Iterator()
+	iter.SeekLowerBound(lower)
+	key, _, ok := iter.Next()
+	if !ok || len(key) <= len(prefix) || !bytes.HasPrefix(key, prefix) {
+		return 0, false
+	}
+	return key[len(prefix)], true
+}
+
+// RollbackTo undoes all the modifications made to the transaction since the
+// given savepoint was taken, including any functions registered with Defer.
+// The savepoint remains valid and can be rolled back to again, but any
+// savepoint taken after it is discarded. An error is returned if the
+// savepoint doesn't belong to this transaction or was discarded.
+func (txn *Txn) RollbackTo(sp *Savepoint) error {
+	if !txn.write {
+		return fmt.Errorf("cannot roll back a read-only transaction")
+	}
+	if txn.rootTxn == nil {
+		return fmt.Errorf("cannot roll back an aborted or committed transaction")
+	}
+
+	depth := -1
+	for i, existing := range txn.savepoints {
+		if existing == sp {
+			depth = i
+			break
+		}
+	}
+	if depth < 0 {
+		return fmt.Errorf("invalid savepoint")
+	}
+
+	// Clone the saved index transactions again so the savepoint itself is
+	// never written to. Indexes first modified after the savepoint fall
+	// back to reading from the root.
+	modified := make(map[tableIndex]*iradix.Txn, len(sp.modified))
+	for key, indexTxn := range sp.modified {
+		modified[key] = indexTxn.Clone()
+	}
+	txn.modified = modified
+	txn.hasTotalBytes = false
+
+	// Change tracking can't be turned off again once enabled
+	if sp.changes != nil || txn.changes == nil {
+		txn.changes = sp.changes
+	} else {
+		txn.changes = make(Changes, 0, 1)
+	}
+
+	txn.after = txn.after[:sp.after]
+	if txn.optimistic != nil {
+		txn.optimistic.ops = txn.optimistic.ops[:sp.ops]
+	}
+	txn.savepoints = txn.savepoints[:depth+1]
+	return nil
+}
+
+// releaseSavepoint discards a savepoint and the ones taken after it,
+// keeping the modifications made since.
+func (txn *Txn) releaseSavepoint(sp *Savepoint) {
+	for i, existing := range txn.savepoints {
+		if existing == sp {
+			txn.savepoints = txn.savepoints[:i]
+			return
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"testing"
+	"time"
+)
+
+func testSavepointObj(id, foo string) *TestObject {
+	return &TestObject{ID: id, Foo: foo, Qux: []string{foo}}
+}
+
+func assertExists(t *testing.T, txn *Txn, exists map[string]bool) {
+	t.Helper()
+	for id, want := range exists {
+		raw, err := txn.First("main", "id", id)
+		noErr(t, err)
+		if (raw != nil) != want {
+			t.Fatalf("object %q: exists=%v want=%v", id, raw != nil, want)
+		}
+	}
+}
+
+func TestTxn_Savepoint_Nested(t *testing.T) {
+	db := testDB(t)
+	txn := db.Txn(true)
+	txn.TrackChanges()
+
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	sp1 := txn.Savepoint()
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	sp2 := txn.Savepoint()
+	noErr(t, txn.Insert("main", testSavepointObj("c", "x")))
+	noErr(t, txn.Delete("main", testSavepointObj("a", "x")))
+	assertExists(t, txn, map[string]bool{"a": false, "b": true, "c": true})
+
+	noErr(t, txn.RollbackTo(sp2))
+	assertExists(t, txn, map[string]bool{"a": true, "b": true, "c": false})
+	if changes := txn.Changes(); len(changes) != 2 {
+		t.Fatalf("bad: %#v", changes)
+	}
+
+	// Rolling back to an outer savepoint discards the inner one
+	noErr(t, txn.RollbackTo(sp1))
+	assertExists(t, txn, map[string]bool{"a": true, "b": false, "c": false})
+	if err := txn.RollbackTo(sp2); err == nil {
+		t.Fatalf("expected error for discarded savepoint")
+	}
+
+	// Secondary indexes are rolled back too
+	iter, err := txn.Get("main", "foo", "x")
+	noErr(t, err)
+	if raw := iter.Next(); raw == nil || raw.(*TestObject).ID != "a" {
+		t.Fatalf("bad: %#v", raw)
+	}
+	if raw := iter.Next(); raw != nil {
+		t.Fatalf("bad: %#v", raw)
+	}
+
+	// The savepoint can be reused
+	noErr(t, txn.Insert("main", testSavepointObj("d", "x")))
+	noErr(t, txn.RollbackTo(sp1))
+	assertExists(t, txn, map[string]bool{"a": true, "d": false})
+
+	noErr(t, txn.Insert("main", testSavepointObj("e", "x")))
+	txn.Commit()
+
+	txn = db.Txn(false)
+	assertExists(t, txn, map[string]bool{"a": true, "b": false, "c": false, "d": false, "e": true})
+}
+
+func TestTxn_Savepoint_Defer(t *testing.T) {
+	db := testDB(t)
+	txn := db.Txn(true)
+
+	res := ""
+	txn.Defer(func() { res += "a" })
+	sp := txn.Savepoint()
+	txn.Defer(func() { res += "b" })
+	noErr(t, txn.RollbackTo(sp))
+	txn.Commit()
+
+	if res != "a" {
+		t.Fatalf("bad: %q", res)
+	}
+}
+
+func TestTxn_Savepoint_ChangeTracking(t *testing.T) {
+	db := testDB(t)
+	txn := db.Txn(true)
+	defer txn.Abort()
+
+	sp := txn.Savepoint()
+	txn.TrackChanges()
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	noErr(t, txn.RollbackTo(sp))
+
+	// Tracking stays enabled after rolling back past TrackChanges
+	changes := txn.Changes()
+	if changes == nil || len(changes) != 0 {
+		t.Fatalf("bad: %#v", changes)
+	}
+}
+
+func TestTxn_Savepoint_Invalid(t *testing.T) {
+	db := testDB(t)
+
+	read := db.Txn(false)
+	if sp := read.Savepoint(); sp != nil {
+		t.Fatalf("read-only transaction should not have savepoints")
+	}
+	if err := read.RollbackTo(nil); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	txn := db.Txn(true)
+	if err := txn.RollbackTo(nil); err == nil {
+		t.Fatalf("expected error")
+	}
+	sp := txn.Savepoint()
+	txn.Commit()
+	if err := txn.RollbackTo(sp); err == nil {
+		t.Fatalf("expected error after commit")
+	}
+
+	other := db.Txn(true)
+	defer other.Abort()
+	if err := other.RollbackTo(sp); err == nil {
+		t.Fatalf("expected error for savepoint of another transaction")
+	}
+}
+
+func TestTxn_Savepoint_Watch(t *testing.T) {
+	db := testDB(t)
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	noErr(t, txn.Insert("main", testSavepointObj("c", "x")))
+	txn.Commit()
+
+	read := db.Txn(false)
+	watchA, _, err := read.FirstWatch("main", "id", "a")
+	noErr(t, err)
+	watchB, _, err := read.FirstWatch("main", "id", "b")
+	noErr(t, err)
+	watchC, _, err := read.FirstWatch("main", "id", "c")
+	noErr(t, err)
+
+	// Modify a before the savepoint, and b both before and after it so the
+	// same nodes are tracked more than once.
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "y")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "y")))
+	sp := txn.Savepoint()
+	noErr(t, txn.Insert("main", testSavepointObj("b", "z")))
+	noErr(t, txn.RollbackTo(sp))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "w")))
+	txn.Commit()
+
+	for name, ch := range map[string]<-chan struct{}{"a": watchA, "b": watchB} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("watch for %q should fire", name)
+		}
+	}
+	select {
+	case <-watchC:
+		t.Fatalf("watch for c should not fire")
+	default:
+	}
+
+	read = db.Txn(false)
+	raw, err := read.First("main", "id", "b")
+	noErr(t, err)
+	if raw.(*TestObject).Foo != "w" {
+		t.Fatalf("bad: %#v", raw)
+	}
+}
+
+func TestTxn_Savepoint_RollbackCommit(t *testing.T) {
+	db := testDB(t)
+
+	// The nodes created before the savepoint are committed after rolling
+	// back, so their watches must still work for the following writes.
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	sp := txn.Savepoint()
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	noErr(t, txn.RollbackTo(sp))
+	txn.Commit()
+
+	read := db.Txn(false)
+	watchA, _, err := read.FirstWatch("main", "id", "a")
+	noErr(t, err)
+	select {
+	case <-watchA:
+		t.Fatalf("watch for a should not fire")
+	default:
+	}
+
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "y")))
+	noErr(t, txn.Insert("main", testSavepointObj("c", "y")))
+	txn.Commit()
+
+	select {
+	case <-watchA:
+	case <-time.After(time.Second):
+		t.Fatalf("watch for a should fire")
+	}
+	assertExists(t, db.Txn(false), map[string]bool{"a": true, "b": false, "c": true})
+}
+
+func TestTxn_Savepoint_RollbackAll(t *testing.T) {
+	db := testDB(t)
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	txn.Commit()
+
+	read := db.Txn(false)
+	watchA, _, err := read.FirstWatch("main", "id", "a")
+	noErr(t, err)
+
+	// Rolled back writes don't fire watches, and leave the nodes of the
+	// committed tree intact for the following writes
+	for i := 0; i < 2; i++ {
+		txn = db.Txn(true)
+		sp := txn.Savepoint()
+		noErr(t, txn.Delete("main", testSavepointObj("a", "x")))
+		noErr(t, txn.RollbackTo(sp))
+		txn.Commit()
+	}
+	select {
+	case <-watchA:
+		t.Fatalf("watch for a should not fire")
+	default:
+	}
+
+	txn = db.Txn(true)
+	noErr(t, txn.Delete("main", testSavepointObj("a", "x")))
+	txn.Commit()
+	select {
+	case <-watchA:
+	case <-time.After(time.Second):
+		t.Fatalf("watch for a should fire")
+	}
+}
+
+func TestTxn_Savepoint_Snapshot(t *testing.T) {
+	db := testDB(t)
+	snap := db.Snapshot()
+
+	txn := snap.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	sp := txn.Savepoint()
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	noErr(t, txn.RollbackTo(sp))
+	txn.Commit()
+
+	txn = snap.Txn(false)
+	assertExists(t, txn, map[string]bool{"a": true, "b": false})
+}
@@ -13,6 +13,10 @@ type DBSchema struct {
 	// Tables is the set of tables within this database. The key is the
 	// table name and must match the Name in TableSchema.
 	Tables map[string]*TableSchema
+
+	// PreCommit hooks are run in order when a write transaction commits,
+	// before the table-level hooks. See PreCommitFunc for details.
+	PreCommit []PreCommitFunc
 }
 
 // Validate validates the schema.
@@ -25,6 +29,12 @@ func (s *DBSchema) Validate() error {
 		return fmt.Errorf("schema has no tables defined")
 	}
 
+	for i, hook := range s.PreCommit {
+		if hook == nil {
+			return fmt.Errorf("pre-commit hook %d is nil", i)
+		}
+	}
+
 	for name, table := range s.Tables {
 		if name != table.Name {
 			return fmt.Errorf("table name mis-match for '%s'", name)
@@ -35,6 +45,15 @@ func (s *DBSchema) Validate() error {
 		}
 	}
 
+	// Foreign keys can only be checked once every table is known
+	for name, table := range s.Tables {
+		for fkName, fk := range table.ForeignKeys {
+			if err := fk.validateReferences(s, table); err != nil {
+				return fmt.Errorf("table %q: foreign key %q: %s", name, fkName, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -47,6 +66,36 @@ type TableSchema struct {
 	// is a unique name for the index and must match the Name in the
 	// IndexSchema.
 	Indexes map[string]*IndexSchema
+
+	// ForeignKeys is the set of references from rows in this table to rows
+	// in other tables. The key is a unique name for the foreign key and must
+	// match the Name in the ForeignKeySchema.
+	ForeignKeys map[string]*ForeignKeySchema
+
+	// Validators are run in order against every object inserted into the
+	// table, before any index is modified.
+	Validators []*Validator
+
+	// PreCommit hooks are run in order when a write transaction that
+	// changed this table commits. They only receive the changes made to
+	// this table.
+	PreCommit []PreCommitFunc
+
+	// Versioned maintains a RowVersion for each row of this table, as
+	// required by Txn.Version, Txn.FirstWithVersion, Txn.InsertCAS and
+	// Txn.DeleteCAS. It costs an extra index write per row written.
+	Versioned bool
+
+	// TTL makes the rows of this table expire, see Reaper.
+	TTL *TTLSchema
+
+	// Quota limits the number of rows and the approximate memory of this
+	// table, enforced by Txn.Insert.
+	Quota *QuotaSchema
+
+	// LRU makes this table a bounded cache evicting its least recently used
+	// rows.
+	LRU *LRUSchema
 }
 
 // Validate is used to validate the table schema
@@ -81,6 +130,54 @@ func (s *TableSchema) Validate() error {
 		}
 	}
 
+	for name, fk := range s.ForeignKeys {
+		if name != fk.Name {
+			return fmt.Errorf("foreign key name mis-match for '%s'", name)
+		}
+
+		if err := fk.Validate(); err != nil {
+			return fmt.Errorf("foreign key %q: %s", name, err)
+		}
+	}
+
+	for i, hook := range s.PreCommit {
+		if hook == nil {
+			return fmt.Errorf("pre-commit hook %d is nil", i)
+		}
+	}
+
+	if s.TTL != nil {
+		if err := s.TTL.Validate(); err != nil {
+			return fmt.Errorf("ttl: %s", err)
+		}
+	}
+
+	if s.Quota != nil {
+		if err := s.Quota.Validate(); err != nil {
+			return fmt.Errorf("quota: %s", err)
+		}
+	}
+
+	if s.LRU != nil {
+		if err := s.LRU.Validate(); err != nil {
+			return fmt.Errorf("lru: %s", err)
+		}
+	}
+
+	validators := make(map[string]struct{}, len(s.Validators))
+	for _, validator := range s.Validators {
+		if validator == nil {
+			return fmt.Errorf("validator is nil")
+		}
+		if err := validator.Validate(); err != nil {
+			return err
+		}
+		if _, ok := validators[validator.Name]; ok {
+			return fmt.Errorf("duplicate validator '%s'", validator.Name)
+		}
+		validators[validator.Name] = struct{}{}
+	}
+
 	return nil
 }
 
@@ -115,3 +212,137 @@ func (s *IndexSchema) Validate() error {
 	}
 	return nil
 }
+
+// ForeignKeyAction describes how a foreign key treats the rows referencing
+// a row that is being deleted.
+type ForeignKeyAction int
+
+const (
+	// Restrict refuses to delete a row while other rows still reference it.
+	// This is the default.
+	Restrict ForeignKeyAction = iota
+
+	// Cascade deletes the referencing rows along with the referenced row.
+	Cascade
+
+	// SetNull clears the reference on a copy of each referencing row and
+	// writes the copy back to the table.
+	SetNull
+)
+
+func (a ForeignKeyAction) String() string {
+	switch a {
+	case Restrict:
+		return "restrict"
+	case Cascade:
+		return "cascade"
+	case SetNull:
+		return "set-null"
+	default:
+		return fmt.Sprintf("ForeignKeyAction(%d)", int(a))
+	}
+}
+
+// ForeignKeySchema is the schema for a reference from the rows of one table
+// to the rows of another. The values extracted from a referencing row must
+// use the same encoding as the foreign index they are looked up in.
+type ForeignKeySchema struct {
+	// Name of the foreign key. This must be unique among a table's set of
+	// foreign keys and must match the key in the map of ForeignKeys for a
+	// TableSchema.
+	Name string
+
+	// Index is the name of an index on the referencing table that extracts
+	// the references. This is the preferred way to declare a foreign key
+	// since it lets deletes find the referencing rows without a table scan.
+	Index string
+
+	// Indexer extracts the references from a referencing row when there is
+	// no suitable index. Deleting a referenced row will scan the whole
+	// referencing table.
+	Indexer Indexer
+
+	// Field is the name of the struct field holding the reference. It is
+	// required for SetNull, which zeroes the field, so the references must
+	// then be extracted by an indexer returning no value for a zero field:
+	// integer and boolean fields can't be used with SetNull. When neither
+	// Index nor Indexer is set, the references are extracted from Field
+	// using the same kind of indexer as the foreign index, which must be one
+	// of the built-in single field indexers.
+	Field string
+
+	// ForeignTable and ForeignIndex name the referenced table and the index
+	// used to look up referenced rows. The foreign index must be unique.
+	ForeignTable string
+	ForeignIndex string
+
+	// OnDelete is the action taken when a referenced row is deleted.
+	OnDelete ForeignKeyAction
+}
+
+// Validate is used to validate the parts of the foreign key that don't
+// depend on other tables.
+func (s *ForeignKeySchema) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("missing foreign key name")
+	}
+	if s.ForeignTable == "" || s.ForeignIndex == "" {
+		return fmt.Errorf("missing foreign table or index for '%s'", s.Name)
+	}
+	if s.Index != "" && s.Indexer != nil {
+		return fmt.Errorf("only one of index or indexer may be set for '%s'", s.Name)
+	}
+	if s.Index == "" && s.Indexer == nil && s.Field == "" {
+		return fmt.Errorf("missing index, indexer or field for '%s'", s.Name)
+	}
+	if s.Indexer != nil {
+		switch s.Indexer.(type) {
+		case SingleIndexer:
+		case MultiIndexer:
+		default:
+			return fmt.Errorf("indexer for '%s' must be a SingleIndexer or MultiIndexer", s.Name)
+		}
+	}
+	switch s.OnDelete {
+	case Restrict, Cascade:
+	case SetNull:
+		if s.Field == "" {
+			return fmt.Errorf("set-null foreign key '%s' requires a field", s.Name)
+		}
+	default:
+		return fmt.Errorf("invalid delete action for '%s': %v", s.Name, s.OnDelete)
+	}
+	return nil
+}
+
+// validateReferences checks the foreign key against the rest of the schema.
+func (s *ForeignKeySchema) validateReferences(db *DBSchema, table *TableSchema) error {
+	foreign, ok := db.Tables[s.ForeignTable]
+	if !ok {
+		return fmt.Errorf("invalid foreign table '%s'", s.ForeignTable)
+	}
+	foreignIndex, ok := foreign.Indexes[s.ForeignIndex]
+	if !ok {
+		return fmt.Errorf("invalid foreign index '%s'", s.ForeignIndex)
+	}
+	if !foreignIndex.Unique {
+		return fmt.Errorf("foreign index '%s' must be unique", s.ForeignIndex)
+	}
+	if s.Index != "" {
+		index, ok := table.Indexes[s.Index]
+		if !ok {
+			return fmt.Errorf("invalid index '%s'", s.Index)
+		}
+		if s.OnDelete == SetNull && !index.AllowMissing {
+			return fmt.Errorf("index '%s' must allow missing values for set-null", s.Index)
+		}
+	}
+	indexer, err := s.indexer(table, foreignIndex)
+	if err != nil {
+		return err
+	}
+	if s.OnDelete == SetNull && !zeroIsMissing(indexer) {
+		return fmt.Errorf("set-null foreign key '%s' requires an indexer treating the zero value of field '%s' as missing", s.Name, s.Field)
+	}
+	return nil
+}
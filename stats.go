@@ -0,0 +1,187 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import "bytes"
+
+// Stats holds the statistics of the tables of a database, see MemDB.Stats.
+type Stats struct {
+	Tables map[string]*TableStats
+}
+
+// TableStats holds the statistics of a table.
+type TableStats struct {
+	// Rows is the number of rows of the table.
+	Rows int
+
+	// ObjectBytes is the approximate memory used by the objects of the
+	// table, as returned by the Size function of its quota, or by
+	// EstimateSize. Memory shared by several objects is counted for each.
+	ObjectBytes int64
+
+	// Indexes holds the statistics of each index of the table.
+	Indexes map[string]*IndexStats
+}
+
+// IndexStats holds the statistics of an index.
+type IndexStats struct {
+	// Entries is the number of keys of the index. It differs from the number
+	// of rows for indexes allowing missing values and for multi-indexes.
+	Entries int
+
+	// Values is the number of distinct index values, and MaxValueEntries the
+	// number of entries of the most common one. They are equal to Entries
+	// and one for unique indexes.
+	Values          int
+	MaxValueEntries int
+
+	// Depth is the number of edges from the root of the radix tree of the
+	// index to its deepest node.
+	Depth int
+
+	// KeyBytes is the total length of the keys of the index, including the
+	// primary keys appended to the values of non-unique indexes.
+	KeyBytes int64
+}
+
+// Stats returns the statistics of every table and index. They are computed
+// against a read transaction, so they are consistent across tables and don't
+// block writers, but they take time proportional to the size of the
+// database.
+func (db *MemDB) Stats() *Stats {
+	txn := db.Txn(false)
+	stats := &Stats{Tables: make(map[string]*TableStats, len(db.schema.Tables))}
+	for table, tableSchema := range db.schema.Tables {
+		tableStats := &TableStats{
+			Indexes: make(map[string]*IndexStats, len(tableSchema.Indexes)),
+		}
+		for name, indexSchema := range tableSchema.Indexes {
+			tableStats.Indexes[name] = txn.indexStats(table, indexSchema)
+		}
+		tableStats.Rows = tableStats.Indexes[id].Entries
+
+		var size func(obj interface{}) int64 = EstimateSize
+		if tableSchema.Quota != nil && tableSchema.Quota.Size != nil {
+			size = tableSchema.Quota.Size
+		}
+		txn.readableIndex(table, id).Root().Walk(func(k []byte, obj interface{}) bool {
+			tableStats.ObjectBytes += size(obj)
+			return false
+		})
+		stats.Tables[table] = tableStats
+	}
+	return stats
+}
+
+// indexStats returns the statistics of an index of a table.
+func (txn *Txn) indexStats(table string, indexSchema *IndexSchema) *IndexStats {
+	stats := new(IndexStats)
+	var (
+		depth  radixDepth
+		values map[string]int
+	)
+	if !indexSchema.Unique {
+		values = make(map[string]int)
+	}
+	txn.readableIndex(table, indexSchema.Name).Root().Walk(func(k []byte, obj interface{}) bool {
+		stats.Entries++
+		stats.KeyBytes += int64(len(k))
+		depth.add(k)
+
+		// Non-unique keys end with the primary key of their object
+		if values != nil {
+			val := k
+			if idVal, err := txn.primaryKey(table, obj); err == nil && bytes.HasSuffix(k, idVal) {
+				val = k[:len(k)-len(idVal)]
+			}
+			values[string(val)]++
+		}
+		return false
+	})
+	stats.Depth = depth.finish()
+
+	if values == nil {
+		stats.Values = stats.Entries
+		if stats.Entries > 0 {
+			stats.MaxValueEntries = 1
+		}
+		return stats
+	}
+	stats.Values = len(values)
+	for _, n := range values {
+		if n > stats.MaxValueEntries {
+			stats.MaxValueEntries = n
+		}
+	}
+	return stats
+}
+
+// radixDepth computes the depth of the radix tree holding a sorted sequence
+// of keys. The nodes of the tree are the keys and the longest common
+// prefixes of adjacent keys, so the tree is rebuilt from them one path at a
+// time, and the height of each node is known once all its keys were added.
+type radixDepth struct {
+	// nodes holds the prefix length and height of the nodes from the root
+	// to the last key.
+	nodes []radixNode
+	last  []byte
+}
+
+type radixNode struct {
+	length int
+	height int
+}
+
+// add adds the next key.
+func (d *radixDepth) add(key []byte) {
+	if d.nodes == nil {
+		d.nodes = []radixNode{{}}
+	}
+	prefix := commonPrefix(d.last, key)
+	d.close(prefix)
+
+	// The key has its own node unless it is the empty key of the root
+	if len(key) > d.nodes[len(d.nodes)-1].length {
+		d.nodes = append(d.nodes, radixNode{length: len(key)})
+	}
+	d.last = key
+}
+
+// close closes the nodes longer than the given prefix, adding a node for the
+// prefix if it is missing.
+func (d *radixDepth) close(prefix int) {
+	var child *radixNode
+	for len(d.nodes) > 1 && d.nodes[len(d.nodes)-1].length > prefix {
+		node := d.nodes[len(d.nodes)-1]
+		d.nodes = d.nodes[:len(d.nodes)-1]
+		if child != nil && child.height+1 > node.height {
+			node.height = child.height + 1
+		}
+		child = &node
+	}
+	if d.nodes[len(d.nodes)-1].length < prefix {
+		d.nodes = append(d.nodes, radixNode{length: prefix})
+	}
+	if top := &d.nodes[len(d.nodes)-1]; child != nil && child.height+1 > top.height {
+		top.height = child.height + 1
+	}
+}
+
+// finish returns the depth of the tree.
+func (d *radixDepth) finish() int {
+	if d.nodes == nil {
+		return 0
+	}
+	d.close(0)
+	return d.nodes[0].height
+}
+
+// commonPrefix returns the length of the longest common prefix of two keys.
+func commonPrefix(a, b []byte) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,92 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"testing"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+func TestStats(t *testing.T) {
+	db := testDB(t)
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", &TestObject{ID: "a", Foo: "x", Qux: []string{"1", "2"}}))
+	noErr(t, txn.Insert("main", &TestObject{ID: "b", Foo: "x", Qux: []string{"1"}}))
+	noErr(t, txn.Insert("main", &TestObject{ID: "c", Foo: "y", Qux: []string{"3"}}))
+	txn.Commit()
+
+	// Writers aren't blocked
+	txn = db.Txn(true)
+	defer txn.Abort()
+	stats := db.Stats()
+
+	main := stats.Tables["main"]
+	if main.Rows != 3 || main.ObjectBytes <= 0 {
+		t.Fatalf("bad table stats: %#v", main)
+	}
+	expected := map[string]IndexStats{
+		"id":  {Entries: 3, Values: 3, MaxValueEntries: 1, Depth: 1, KeyBytes: 6},
+		"foo": {Entries: 3, Values: 2, MaxValueEntries: 2, Depth: 2, KeyBytes: 12},
+		"qux": {Entries: 4, Values: 3, MaxValueEntries: 2, Depth: 2, KeyBytes: 16},
+	}
+	for name, want := range expected {
+		if got := main.Indexes[name]; got == nil || *got != want {
+			t.Fatalf("bad stats of index %s: %#v", name, got)
+		}
+	}
+
+	// The object sizes of a quota are used
+	schema := testValidSchema()
+	schema.Tables["main"].Quota = &QuotaSchema{Size: func(interface{}) int64 { return 10 }}
+	db, err := NewMemDB(schema)
+	noErr(t, err)
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	txn.Commit()
+	if main := db.Stats().Tables["main"]; main.ObjectBytes != 20 {
+		t.Fatalf("bad table stats: %#v", main)
+	}
+
+	// Empty tables
+	if main := testDB(t).Stats().Tables["main"]; main.Rows != 0 || *main.Indexes["id"] != (IndexStats{}) {
+		t.Fatalf("bad empty table stats: %#v", main)
+	}
+}
+
+func TestStats_RadixDepth(t *testing.T) {
+	cases := []struct {
+		keys  []string
+		depth int
+	}{
+		{nil, 0},
+		{[]string{""}, 0},
+		{[]string{"a"}, 1},
+		{[]string{"a", "b"}, 1},
+		{[]string{"", "a"}, 1},
+		{[]string{"a", "ab"}, 2},
+		{[]string{"ab", "ac"}, 2},
+		{[]string{"aab", "aac", "ab"}, 3},
+		{[]string{"", "a", "aa", "aaa"}, 3},
+		{[]string{"abc", "abd", "b"}, 2},
+		{[]string{"abcd", "abce", "abd", "b"}, 3},
+		{[]string{"abcd", "abce", "abd", "abdf", "b", "bc"}, 3},
+	}
+	for _, tc := range cases {
+		tree := iradix.New()
+		for _, key := range tc.keys {
+			tree, _, _ = tree.Insert([]byte(key), nil)
+		}
+
+		var depth radixDepth
+		tree.Root().Walk(func(k []byte, v interface{}) bool {
+			depth.add(k)
+			return false
+		})
+		if got := depth.finish(); got != tc.depth {
+			t.Fatalf("bad depth of %q: got %d, want %d", tc.keys, got, tc.depth)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+const (
+	// ttlIndex is the name of the internal index of each table with a TTL,
+	// mapping the expiry time of rows followed by their primary key to the
+	// rows.
+	ttlIndex = "\x00ttl"
+)
+
+const (
+	// defaultReaperBatchSize and defaultReaperInterval are the defaults of
+	// ReaperBatchSize and ReaperInterval.
+	defaultReaperBatchSize = 1000
+	defaultReaperInterval  = time.Second
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// TTLSchema declares when the rows of a table expire. Expired rows are
+// deleted by a Reaper, they remain visible until then. Exactly one of Field
+// and Func must be set.
+type TTLSchema struct {
+	// Field is the name of a time.Time or *time.Time field holding the
+	// expiry time of a row. Rows with a zero time or a nil pointer never
+	// expire.
+	Field string
+
+	// Func returns the expiry time of a row, and false if it never expires.
+	Func func(obj interface{}) (time.Time, bool)
+}
+
+// Validate is used to validate the TTL schema.
+func (s *TTLSchema) Validate() error {
+	if (s.Field == "") == (s.Func == nil) {
+		return fmt.Errorf("exactly one of field or func must be set")
+	}
+	return nil
+}
+
+// expiry returns the expiry time of a row, and whether it expires.
+func (s *TTLSchema) expiry(obj interface{}) (time.Time, bool, error) {
+	if s.Func != nil {
+		t, ok := s.Func(obj)
+		return t, ok, nil
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	fv := fieldByName(v, s.Field)
+	if !fv.IsValid() {
+		return time.Time{}, false, fmt.Errorf("field '%s' for %#v is invalid", s.Field, obj)
+	}
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return time.Time{}, false, nil
+		}
+		fv = fv.Elem()
+	}
+	if fv.Type() != timeType {
+		return time.Time{}, false, fmt.Errorf("field '%s' is not a time.Time", s.Field)
+	}
+	t := fv.Interface().(time.Time)
+	return t, !t.IsZero(), nil
+}
+
+// ttlKey returns the key of a row in the TTL index.
+func ttlKey(expiry time.Time, idVal []byte) []byte {
+	key := encodeInt(expiry.UnixNano(), 8)
+	return append(key, idVal...)
+}
+
+// rowExpiry computes the key of a row in the TTL index of its table, if
+// the table has a TTL and the row expires.
+func rowExpiry(tableSchema *TableSchema, obj interface{}, idVal []byte) ([]byte, error) {
+	if tableSchema.TTL == nil {
+		return nil, nil
+	}
+	expiry, ok, err := tableSchema.TTL.expiry(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expiry: %v", err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return ttlKey(expiry, idVal), nil
+}
+
+// updateExpiry replaces the key of a row in the TTL index of its table.
+// Either key may be nil when the row doesn't expire or doesn't exist.
+func (txn *Txn) updateExpiry(table string, oldKey, newKey []byte, obj interface{}) {
+	if oldKey == nil && newKey == nil {
+		return
+	}
+	ttlTxn := txn.writableIndex(table, ttlIndex)
+	if oldKey != nil && !bytes.Equal(oldKey, newKey) {
+		ttlTxn.Delete(oldKey)
+	}
+	if newKey != nil {
+		ttlTxn.Insert(newKey, obj)
+	}
+}
+
+// removeExpiry removes a deleted row from the TTL index of its table.
+func (txn *Txn) removeExpiry(tableSchema *TableSchema, existing interface{}, idVal []byte) {
+	// The row was indexed when it was inserted, so this can't fail
+	key, _ := rowExpiry(tableSchema, existing, idVal)
+	txn.updateExpiry(tableSchema.Name, key, nil, nil)
+}
+
+// Reaper deletes the expired rows of the tables with a TTL, see TTLSchema.
+// Rows are deleted in batches, each in its own write transaction, so the
+// deletes are recorded in Changes, enforce foreign keys, run pre-commit
+// hooks and notify watches like any other delete.
+type Reaper struct {
+	db       *MemDB
+	now      func() time.Time
+	batch    int
+	interval time.Duration
+}
+
+// ReaperOption configures a Reaper.
+type ReaperOption func(*Reaper)
+
+// ReaperClock sets the clock used to tell which rows expired. It defaults
+// to time.Now.
+func ReaperClock(now func() time.Time) ReaperOption {
+	return func(r *Reaper) {
+		r.now = now
+	}
+}
+
+// ReaperBatchSize sets the maximum number of rows deleted by each write
+// transaction. It defaults to 1000, which is also used for values that
+// aren't positive.
+func ReaperBatchSize(n int) ReaperOption {
+	return func(r *Reaper) {
+		r.batch = n
+	}
+}
+
+// ReaperInterval sets the time between two passes of Run. It defaults to
+// one second.
+func ReaperInterval(d time.Duration) ReaperOption {
+	return func(r *Reaper) {
+		r.interval = d
+	}
+}
+
+// NewReaper returns a reaper of the expired rows of db.
+func NewReaper(db *MemDB, opts ...ReaperOption) *Reaper {
+	r := &Reaper{
+		db:       db,
+		now:      time.Now,
+		batch:    defaultReaperBatchSize,
+		interval: defaultReaperInterval,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.batch <= 0 {
+		r.batch = defaultReaperBatchSize
+	}
+	if r.interval <= 0 {
+		r.interval = defaultReaperInterval
+	}
+	return r
+}
+
+// Run reaps expired rows periodically until the context is done, and then
+// returns the context error. Rows that fail to be deleted, for example
+// because a foreign key restricts it, are retried by the next pass.
+func (r *Reaper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		_, _ = r.Reap()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reap deletes every row that expired at the current time of the clock and
+// returns the number of deleted rows. Rows that fail to be deleted are
+// skipped and their errors are joined in the returned error.
+func (r *Reaper) Reap() (int, error) {
+	now := r.now()
+	tables := make([]string, 0, len(r.db.schema.Tables))
+	for name, tableSchema := range r.db.schema.Tables {
+		if tableSchema.TTL != nil {
+			tables = append(tables, name)
+		}
+	}
+	sort.Strings(tables)
+
+	var (
+		total int
+		errs  []error
+	)
+	for _, table := range tables {
+		var from []byte
+		for {
+			n, next, batchErrs := r.reapBatch(table, now, from)
+			total += n
+			errs = append(errs, batchErrs...)
+			if next == nil {
+				break
+			}
+			from = next
+		}
+	}
+	return total, errors.Join(errs...)
+}
+
+// reapBatch deletes a batch of the expired rows of a table, starting at the
+// given key of the TTL index. It returns the key to continue from, or nil
+// once there are no more expired rows.
+func (r *Reaper) reapBatch(table string, now time.Time, from []byte) (int, []byte, []error) {
+	raw, _ := r.db.getRoot().Get(indexPath(table, ttlIndex))
+	iter := raw.(*iradix.Tree).Root().Iterator()
+	iter.SeekLowerBound(from)
+
+	limit := encodeInt(now.UnixNano(), 8)
+	var (
+		objs []interface{}
+		next []byte
+	)
+	for key, obj, ok := iter.Next(); ok; key, obj, ok = iter.Next() {
+		if bytes.Compare(key[:8], limit) > 0 {
+			break
+		}
+		if len(objs) == r.batch {
+			next = key
+			break
+		}
+		objs = append(objs, obj)
+	}
+	if len(objs) == 0 {
+		return 0, nil, nil
+	}
+
+	// A failed delete may leave the transaction half modified, so the rows
+	// of a failed batch are retried one at a time.
+	n, errs := r.deleteExpired(table, limit, objs)
+	if errs == nil || len(objs) == 1 {
+		return n, next, errs
+	}
+	errs = nil
+	for _, obj := range objs {
+		deleted, objErrs := r.deleteExpired(table, limit, []interface{}{obj})
+		n += deleted
+		errs = append(errs, objErrs...)
+	}
+	return n, next, errs
+}
+
+// deleteExpired deletes the given rows in a write transaction, unless they
+// were deleted or updated to expire after the limit since they were read.
+// Nothing is deleted if any delete fails.
+func (r *Reaper) deleteExpired(table string, limit []byte, objs []interface{}) (int, []error) {
+	tableSchema := r.db.schema.Tables[table]
+	txn := r.db.Txn(true)
+	defer txn.Abort()
+
+	n := 0
+	for _, obj := range objs {
+		idVal, err := txn.primaryKey(table, obj)
+		if err != nil {
+			return 0, []error{err}
+		}
+		existing, ok := txn.indexGet(table, id, idVal)
+		if !ok {
+			continue
+		}
+		if key, _ := rowExpiry(tableSchema, existing, idVal); key == nil || bytes.Compare(key[:8], limit) > 0 {
+			continue
+		}
+		if err := txn.Delete(table, existing); err != nil {
+			return 0, []error{fmt.Errorf("failed to delete expired row of table '%s': %w", table, err)}
+		}
+		n++
+	}
+	if err := txn.CommitErr(); err != nil {
+		return 0, []error{err}
+	}
+	return n, nil
+}
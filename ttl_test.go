@@ -0,0 +1,260 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type TestSession struct {
+	ID      string
+	Node    string
+	Expires time.Time
+}
+
+var testTTLEpoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func testTTLSchema(ttl *TTLSchema) *DBSchema {
+	return &DBSchema{
+		Tables: map[string]*TableSchema{
+			"sessions": &TableSchema{
+				Name: "sessions",
+				Indexes: map[string]*IndexSchema{
+					"id": &IndexSchema{
+						Name:    "id",
+						Unique:  true,
+						Indexer: &StringFieldIndex{Field: "ID"},
+					},
+				},
+				TTL: ttl,
+			},
+		},
+	}
+}
+
+// testClock is a clock for reapers that only moves when told to.
+type testClock struct {
+	l   sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func testSession(id string, ttl time.Duration) *TestSession {
+	s := &TestSession{ID: id}
+	if ttl != 0 {
+		s.Expires = testTTLEpoch.Add(ttl)
+	}
+	return s
+}
+
+func testSessionIDs(t *testing.T, db *MemDB) string {
+	t.Helper()
+	iter, err := db.Txn(false).Get("sessions", "id")
+	noErr(t, err)
+	var ids []string
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		ids = append(ids, raw.(*TestSession).ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestReaper(t *testing.T) {
+	db, err := NewMemDB(testTTLSchema(&TTLSchema{Field: "Expires"}), WithEvents(0, 10))
+	noErr(t, err)
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("sessions", testSession("a", time.Second)))
+	noErr(t, txn.Insert("sessions", testSession("b", 2*time.Second)))
+	noErr(t, txn.Insert("sessions", testSession("c", 3*time.Second)))
+	noErr(t, txn.Insert("sessions", testSession("forever", 0)))
+	txn.Commit()
+
+	// Extending a row moves its expiry
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("sessions", testSession("b", 5*time.Second)))
+	txn.Commit()
+
+	clock := &testClock{now: testTTLEpoch}
+	reaper := NewReaper(db, ReaperClock(clock.Now))
+	if n, err := reaper.Reap(); err != nil || n != 0 {
+		t.Fatalf("bad: %d %v", n, err)
+	}
+
+	watch, _, err := db.Txn(false).FirstWatch("sessions", "id", "a")
+	noErr(t, err)
+	sub, err := db.Subscribe(context.Background(), nil)
+	noErr(t, err)
+	defer sub.Close()
+
+	clock.Advance(3 * time.Second)
+	if n, err := reaper.Reap(); err != nil || n != 2 {
+		t.Fatalf("bad: %d %v", n, err)
+	}
+	if ids := testSessionIDs(t, db); ids != "b,forever" {
+		t.Fatalf("bad: %s", ids)
+	}
+
+	// The deletes are regular changes and notify watches
+	select {
+	case <-watch:
+	case <-time.After(time.Second):
+		t.Fatalf("watch should fire")
+	}
+	event := testNextEvent(t, sub)
+	if len(event.Changes) != 2 || !event.Changes[0].Deleted() ||
+		event.Changes[0].Before.(*TestSession).ID != "a" {
+		t.Fatalf("bad: %#v", event.Changes)
+	}
+
+	clock.Advance(time.Hour)
+	if n, err := reaper.Reap(); err != nil || n != 1 {
+		t.Fatalf("bad: %d %v", n, err)
+	}
+	if ids := testSessionIDs(t, db); ids != "forever" {
+		t.Fatalf("bad: %s", ids)
+	}
+}
+
+func TestReaper_Batches(t *testing.T) {
+	db, err := NewMemDB(testTTLSchema(&TTLSchema{Field: "Expires"}))
+	noErr(t, err)
+	txn := db.Txn(true)
+	for i := 0; i < 25; i++ {
+		noErr(t, txn.Insert("sessions", testSession(fmt.Sprintf("s%02d", i), time.Duration(i+1)*time.Second)))
+	}
+	txn.Commit()
+	start := db.Txn(false).CommitIndex()
+
+	clock := &testClock{now: testTTLEpoch.Add(time.Minute)}
+	reaper := NewReaper(db, ReaperClock(clock.Now), ReaperBatchSize(10))
+	if n, err := reaper.Reap(); err != nil || n != 25 {
+		t.Fatalf("bad: %d %v", n, err)
+	}
+	if index := db.Txn(false).CommitIndex(); index != start+3 {
+		t.Fatalf("bad: %d", index)
+	}
+}
+
+func TestReaper_Func(t *testing.T) {
+	db, err := NewMemDB(testTTLSchema(&TTLSchema{
+		Func: func(obj interface{}) (time.Time, bool) {
+			s := obj.(*TestSession)
+			return s.Expires, s.Node != "pinned"
+		},
+	}))
+	noErr(t, err)
+
+	pinned := testSession("b", time.Second)
+	pinned.Node = "pinned"
+	noErr(t, db.BulkLoad("sessions", []interface{}{testSession("a", time.Second), pinned}))
+
+	clock := &testClock{now: testTTLEpoch.Add(time.Second)}
+	if n, err := NewReaper(db, ReaperClock(clock.Now)).Reap(); err != nil || n != 1 {
+		t.Fatalf("bad: %d %v", n, err)
+	}
+	if ids := testSessionIDs(t, db); ids != "b" {
+		t.Fatalf("bad: %s", ids)
+	}
+}
+
+func TestReaper_ForeignKeys(t *testing.T) {
+	schema := testForeignKeySchema(Restrict)
+	schema.Tables["nodes"].TTL = &TTLSchema{
+		Func: func(obj interface{}) (time.Time, bool) {
+			return testTTLEpoch, true
+		},
+	}
+	db, err := NewMemDB(schema)
+	noErr(t, err)
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("nodes", &TestNode{ID: "node1"}))
+	noErr(t, txn.Insert("nodes", &TestNode{ID: "node2"}))
+	noErr(t, txn.Insert("allocs", &TestAlloc{ID: "alloc1", NodeID: "node1"}))
+	txn.Commit()
+
+	// The referenced node can't be deleted, but the other one is
+	clock := &testClock{now: testTTLEpoch}
+	reaper := NewReaper(db, ReaperClock(clock.Now))
+	n, err := reaper.Reap()
+	if err == nil || n != 1 {
+		t.Fatalf("bad: %d %v", n, err)
+	}
+	raw, err := db.Txn(false).First("nodes", "id", "node1")
+	noErr(t, err)
+	if raw == nil {
+		t.Fatalf("node1 should remain")
+	}
+
+	// It is retried once it is no longer referenced
+	txn = db.Txn(true)
+	noErr(t, txn.Delete("allocs", &TestAlloc{ID: "alloc1"}))
+	txn.Commit()
+	if n, err := reaper.Reap(); err != nil || n != 1 {
+		t.Fatalf("bad: %d %v", n, err)
+	}
+}
+
+func TestReaper_Run(t *testing.T) {
+	db, err := NewMemDB(testTTLSchema(&TTLSchema{Field: "Expires"}))
+	noErr(t, err)
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("sessions", testSession("a", time.Second)))
+	txn.Commit()
+	watch, _, err := db.Txn(false).FirstWatch("sessions", "id", "a")
+	noErr(t, err)
+
+	clock := &testClock{now: testTTLEpoch}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- NewReaper(db, ReaperClock(clock.Now), ReaperInterval(time.Millisecond)).Run(ctx)
+	}()
+
+	clock.Advance(time.Second)
+	select {
+	case <-watch:
+	case <-time.After(time.Second):
+		t.Fatalf("row should be reaped")
+	}
+
+	cancel()
+	if err := <-errCh; err != context.Canceled {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestTTLSchema_Validate(t *testing.T) {
+	for _, ttl := range []*TTLSchema{
+		{},
+		{Field: "Expires", Func: func(interface{}) (time.Time, bool) { return time.Time{}, false }},
+	} {
+		if err := testTTLSchema(ttl).Validate(); err == nil {
+			t.Fatalf("expected error for %#v", ttl)
+		}
+	}
+
+	// Rows without a valid expiry field are refused
+	db, err := NewMemDB(testTTLSchema(&TTLSchema{Field: "Node"}))
+	noErr(t, err)
+	txn := db.Txn(true)
+	defer txn.Abort()
+	if err := txn.Insert("sessions", testSession("a", time.Second)); err == nil {
+		t.Fatalf("expected error")
+	}
+}
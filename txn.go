@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strings"
 	"sync/atomic"
+	"time"
 	"unsafe"
 
 	iradix "github.com/hashicorp/go-immutable-radix"
@@ -41,6 +42,48 @@ type Txn struct {
 	changes Changes
 
 	modified map[tableIndex]*iradix.Txn
+
+	// untracked is set once a savepoint has been taken. The index
+	// transactions in modified then don't track mutations, so that they can
+	// be swapped out by RollbackTo, and the keys to notify are found at
+	// commit, see replayMutations.
+	untracked bool
+
+	// keys counts the keys written to each index, if metrics are enabled.
+	keys map[tableIndex]*int
+
+	// savepoints is the stack of savepoints that can be rolled back to.
+	savepoints []*Savepoint
+
+	// totalBytes is the sum of the tracked sizes of the objects of every
+	// table as seen by the transaction, once hasTotalBytes is set. It is
+	// only computed when the database has a memory budget.
+	totalBytes    int64
+	hasTotalBytes bool
+
+	// stamp is the commit stamp of the rows written by this transaction.
+	stamp *commitStamp
+
+	// wait is the time spent waiting for the writer lock.
+	wait time.Duration
+
+	// locked holds the tables whose writer locks are held by the
+	// transaction. tables is the set of writable tables, or nil if all the
+	// tables are writable.
+	locked []string
+	tables map[string]struct{}
+
+	// optimistic is set for transactions created with OptimisticTxn.
+	optimistic *optimisticState
+
+	// replica is set for transactions applying replicated changes. They
+	// skip validators, foreign keys, quotas and pre-commit hooks, which were
+	// already enforced by the leader.
+	replica bool
+
+	// applying is set while Apply replays changes. Validators and foreign
+	// keys are skipped, since a change set may transiently break them.
+	applying bool
 }
 
 // TrackChanges enables change tracking for the transaction. If called at any
@@ -48,13 +91,20 @@ type Txn struct {
 // retrieved using ChangeSet. Once this has been called on a transaction it
 // can't be unset. As with other Txn methods it's not safe to call this from a
 // different goroutine than the one making mutations or committing the
-// transaction.
+// transaction. Change tracking is enabled automatically for write
+// transactions when the schema declares pre-commit hooks.
 func (txn *Txn) TrackChanges() {
 	if txn.changes == nil {
 		txn.changes = make(Changes, 0, 1)
 	}
 }
 
+// WaitTime returns how long the transaction waited for the writer lock
+// before starting. It is always zero for read transactions.
+func (txn *Txn) WaitTime() time.Duration {
+	return txn.wait
+}
+
 // readableIndex returns a transaction usable for reading the given index in a
 // table. If the transaction is a write transaction with modifications, a clone of the
 // modified index will be returned.
@@ -75,9 +125,26 @@ func (txn *Txn) readableIndex(table, index string) *iradix.Txn {
 	return indexTxn
 }
 
+// indexGet does an exact lookup of a key in the given index, taking any
+// modifications made by this transaction into account. Unlike readableIndex
+// this doesn't clone a modified index, so it is cheap to call from within
+// write operations.
+func (txn *Txn) indexGet(table, index string, key []byte) (interface{}, bool) {
+	txn.recordRead(table, index, key)
+	if txn.write && txn.modified != nil {
+		if exist, ok := txn.modified[tableIndex{table, index}]; ok {
+			return exist.Get(key)
+		}
+	}
+
+	path := indexPath(table, index)
+	raw, _ := txn.rootTxn.Get(path)
+	return raw.(*iradix.Tree).Get(key)
+}
+
 // writableIndex returns a transaction usable for modifying the
 // given index in a table.
-func (txn *Txn) writableIndex(table, index string) *iradix.Txn {
+func (txn *Txn) writableIndex(table, index string) indexWriter {
 	if txn.modified == nil {
 		txn.modified = make(map[tableIndex]*iradix.Txn)
 	}
@@ -86,7 +153,7 @@ func (txn *Txn) writableIndex(table, index string) *iradix.Txn {
 	key := tableIndex{table, index}
 	exist, ok := txn.modified[key]
 	if ok {
-		return exist
+		return indexWriter{Txn: exist, keys: txn.keyCounter(key)}
 	}
 
 	// Start a new transaction
@@ -96,12 +163,36 @@ func (txn *Txn) writableIndex(table, index string) *iradix.Txn {
 
 	// If we are the primary DB, enable mutation tracking. Snapshots should
 	// not notify, otherwise we will trigger watches on the primary DB when
-	// the writes will not be visible.
-	indexTxn.TrackMutate(txn.db.primary)
+	// the writes will not be visible. Once a savepoint has been taken the
+	// mutations are found at commit instead, see Savepoint.
+	indexTxn.TrackMutate(txn.db.primary && txn.optimistic == nil && !txn.untracked)
 
 	// Keep this open for the duration of the txn
 	txn.modified[key] = indexTxn
-	return indexTxn
+	return indexWriter{Txn: indexTxn, keys: txn.keyCounter(key)}
+}
+
+// indexWriter is an index transaction returned by writableIndex. Writes are
+// counted in keys when metrics are enabled.
+type indexWriter struct {
+	*iradix.Txn
+	keys *int
+}
+
+// Insert is used to add or update a given key in the index.
+func (w indexWriter) Insert(k []byte, v interface{}) {
+	if w.keys != nil {
+		*w.keys++
+	}
+	w.Txn.Insert(k, v)
+}
+
+// Delete is used to delete a given key from the index.
+func (w indexWriter) Delete(k []byte) {
+	if w.keys != nil {
+		*w.keys++
+	}
+	w.Txn.Delete(k)
 }
 
 // Abort is used to cancel this transaction.
@@ -118,55 +209,159 @@ func (txn *Txn) Abort() {
 		return
 	}
 
+	if txn.db.metrics != nil {
+		txn.db.metrics.IncrCounter(MetricTxnAbort, nil, 1)
+	}
+
 	// Clear the txn
 	txn.rootTxn = nil
 	txn.modified = nil
+	txn.keys = nil
+	txn.savepoints = nil
 	txn.changes = nil
+	txn.optimistic = nil
 
-	// Release the writer lock since this is invalid
-	txn.db.writer.Unlock()
+	// Release the writer locks since this is invalid
+	txn.db.locks.unlock(txn.locked)
 }
 
 // Commit is used to finalize this transaction.
 // This is a noop for read transactions,
 // already aborted or committed transactions.
+//
+// If a pre-commit hook fails the transaction is aborted instead. Use
+// CommitErr to find out whether the transaction was committed.
 func (txn *Txn) Commit() {
+	_ = txn.CommitErr()
+}
+
+// CommitErr is used to finalize this transaction, returning an error if the
+// transaction had to be aborted instead. This happens when a pre-commit hook
+// returns an error, or when the transaction no longer fits in the memory
+// budget of the database, see WithMemoryBudget. This is a noop for read
+// transactions, already aborted or committed transactions.
+func (txn *Txn) CommitErr() error {
 	// Noop for a read transaction
 	if !txn.write {
-		return
+		return nil
 	}
 
 	// Check if already aborted or committed
 	if txn.rootTxn == nil {
-		return
+		return nil
+	}
+
+	if metrics := txn.db.metrics; metrics != nil {
+		start := time.Now()
+		defer func() {
+			metrics.AddSample(MetricTxnCommit, nil, milliseconds(time.Since(start)))
+		}()
+	}
+
+	// Optimistic transactions are replayed in a new transaction
+	if txn.optimistic != nil {
+		return txn.commitOptimistic()
+	}
+
+	// Give the pre-commit hooks a chance to veto the transaction
+	if txn.db.preCommit && !txn.replica {
+		if err := txn.preCommit(); err != nil {
+			txn.Abort()
+			return err
+		}
+	}
+
+	txn.db.commitLock.Lock()
+	if !txn.replica {
+		if err := txn.checkBudget(); err != nil {
+			txn.db.commitLock.Unlock()
+			txn.Abort()
+			return err
+		}
 	}
+	txn.commit()
+	return nil
+}
 
-	// Commit each sub-transaction scoped to (table, index)
+// commit merges the modified indexes into the root of the DB, issues the
+// mutation notifications and releases the writer locks. The commit lock
+// must be held, and is released.
+func (txn *Txn) commit() {
+	// Writers of other tables may have committed since the transaction
+	// started, so the modified indexes are merged into the current root.
+	// They can't have touched the tables locked by this transaction.
+	rootTxn := txn.db.getRoot().Txn()
+	if txn.db.primary {
+		rootTxn.TrackMutate(true)
+	}
+
+	// Commit each sub-transaction scoped to (table, index). Once a
+	// savepoint has been taken, the mutations are replayed to find the
+	// channels to close.
+	changed := make(map[string]struct{})
+	var notify []*iradix.Txn
+	var diffs map[tableIndex]indexDiff
 	for key, subTxn := range txn.modified {
 		path := indexPath(key.Table, key.Index)
 		final := subTxn.CommitOnly()
-		txn.rootTxn.Insert(path, final)
+		raw, _ := rootTxn.Get(path)
+		if existing := raw.(*iradix.Tree); existing.Root() != final.Root() {
+			changed[key.Table] = struct{}{}
+			if txn.untracked && txn.db.primary {
+				notify = append(notify, replayMutations(existing, final))
+			}
+			if txn.db.metrics != nil && txn.db.primary {
+				if diffs == nil {
+					diffs = make(map[tableIndex]indexDiff)
+				}
+				diffs[key] = indexDiff{before: existing.Root(), after: final.Root()}
+			}
+		}
+		rootTxn.Insert(path, final)
 	}
 
-	// Update the root of the DB
-	newRoot := txn.rootTxn.CommitOnly()
+	// Advance the commit indexes if anything was actually modified
+	var index uint64
+	if len(changed) > 0 {
+		index = txn.advanceCommitIndex(rootTxn, changed)
+	}
+
+	// Update the root of the DB, and retain it if history is enabled
+	newRoot := rootTxn.CommitOnly()
 	atomic.StorePointer(&txn.db.root, unsafe.Pointer(newRoot))
+	if txn.db.history != nil && len(changed) > 0 {
+		txn.db.history.add(index, newRoot, time.Now())
+	}
+
+	// Publish the changes while holding the lock so events are in order
+	if txn.db.events != nil && len(changed) > 0 {
+		txn.db.events.publish(index, txn.Changes())
+	}
+	txn.db.commitLock.Unlock()
 
 	// Now issue all of the mutation updates (this is safe to call
 	// even if mutation tracking isn't enabled); we do this after
 	// the root pointer is swapped so that waking responders will
 	// see the new state.
+	if txn.db.metrics != nil {
+		txn.reportCommit(diffs)
+	}
 	for _, subTxn := range txn.modified {
 		subTxn.Notify()
 	}
-	txn.rootTxn.Notify()
+	for _, notifyTxn := range notify {
+		notifyTxn.Notify()
+	}
+	rootTxn.Notify()
 
 	// Clear the txn
 	txn.rootTxn = nil
 	txn.modified = nil
+	txn.keys = nil
+	txn.savepoints = nil
 
-	// Release the writer lock since this is invalid
-	txn.db.writer.Unlock()
+	// Release the writer locks since this is invalid
+	txn.db.locks.unlock(txn.locked)
 
 	// Run the deferred functions, if any
 	for i := len(txn.after); i > 0; i-- {
@@ -184,12 +379,26 @@ func (txn *Txn) Insert(table string, obj interface{}) error {
 	if !txn.write {
 		return fmt.Errorf("cannot insert in read-only transaction")
 	}
+	return txn.recordWrite(func(txn *Txn) error {
+		return txn.insert(table, obj)
+	})
+}
 
+// insert implements Insert.
+func (txn *Txn) insert(table string, obj interface{}) error {
 	// Get the table schema
 	tableSchema, ok := txn.db.schema.Tables[table]
 	if !ok {
 		return fmt.Errorf("invalid table '%s'", table)
 	}
+	if err := txn.checkLocked(table); err != nil {
+		return err
+	}
+
+	// Run the validators before touching any of the indexes
+	if err := txn.validate(tableSchema, obj); err != nil {
+		return err
+	}
 
 	// Get the primary ID of the object
 	idSchema := tableSchema.Indexes[id]
@@ -204,8 +413,40 @@ func (txn *Txn) Insert(table string, obj interface{}) error {
 
 	// Lookup the object by ID first, to see if this is an update
 	idTxn := txn.writableIndex(table, id)
+	txn.recordRead(table, id, idVal)
 	existing, update := idTxn.Get(idVal)
 
+	// Enforce foreign keys before touching any of the indexes
+	if err := txn.checkForeignKeys(table, existing, obj); err != nil {
+		return err
+	}
+	expiryKey, err := rowExpiry(tableSchema, obj, idVal)
+	if err != nil {
+		return err
+	}
+
+	// Enforce the quotas as if the rows to evict were already gone, and
+	// only evict them once the object is known to fit
+	var victims []interface{}
+	if tableSchema.LRU != nil && !update && !txn.replica && txn.optimistic == nil {
+		victims = txn.lruVictims(tableSchema)
+	}
+	usage, tracked, err := txn.insertUsage(tableSchema, existing, obj, victims)
+	if err != nil {
+		return err
+	}
+	if len(victims) > 0 {
+		if err := txn.evict(table, victims); err != nil {
+			return err
+		}
+
+		// Foreign key actions of the evicted rows may have changed the
+		// usage further
+		if len(txn.db.references[table]) > 0 {
+			usage, tracked, _ = txn.insertUsage(tableSchema, existing, obj, nil)
+		}
+	}
+
 	// On an update, there is an existing object with the given
 	// primary ID. We do the update by deleting the current object
 	// and inserting the new object.
@@ -291,6 +532,22 @@ func (txn *Txn) Insert(table string, obj interface{}) error {
 			indexTxn.Insert(val, obj)
 		}
 	}
+	if tableSchema.Versioned {
+		txn.stampVersion(table, idVal)
+	}
+	if tableSchema.TTL != nil {
+		var oldKey []byte
+		if update {
+			oldKey, _ = rowExpiry(tableSchema, existing, idVal)
+		}
+		txn.updateExpiry(table, oldKey, expiryKey, obj)
+	}
+	if tracked {
+		txn.setUsage(table, usage)
+	}
+	if tableSchema.LRU != nil {
+		txn.touch(table, idVal, obj)
+	}
 	if txn.changes != nil {
 		txn.changes = append(txn.changes, Change{
 			Table:      table,
@@ -299,6 +556,9 @@ func (txn *Txn) Insert(table string, obj interface{}) error {
 			primaryKey: idVal,
 		})
 	}
+	if txn.db.metrics != nil {
+		txn.db.metrics.IncrCounter(MetricInsert, tableLabels(table), 1)
+	}
 	return nil
 }
 
@@ -308,12 +568,21 @@ func (txn *Txn) Delete(table string, obj interface{}) error {
 	if !txn.write {
 		return fmt.Errorf("cannot delete in read-only transaction")
 	}
+	return txn.recordWrite(func(txn *Txn) error {
+		return txn.delete(table, obj)
+	})
+}
 
+// delete implements Delete.
+func (txn *Txn) delete(table string, obj interface{}) error {
 	// Get the table schema
 	tableSchema, ok := txn.db.schema.Tables[table]
 	if !ok {
 		return fmt.Errorf("invalid table '%s'", table)
 	}
+	if err := txn.checkLocked(table); err != nil {
+		return err
+	}
 
 	// Get the primary ID of the object
 	idSchema := tableSchema.Indexes[id]
@@ -328,11 +597,26 @@ func (txn *Txn) Delete(table string, obj interface{}) error {
 
 	// Lookup the object by ID first, check if we should continue
 	idTxn := txn.writableIndex(table, id)
+	txn.recordRead(table, id, idVal)
 	existing, ok := idTxn.Get(idVal)
 	if !ok {
 		return ErrNotFound
 	}
 
+	// Find the rows referencing the object, refusing the delete if a
+	// foreign key restricts it
+	actions, err := txn.foreignKeyActions(table, existing)
+	if err != nil {
+		return err
+	}
+
+	// The actions may fail further down the references, in which case the
+	// delete is rolled back along with the actions already applied
+	var sp *Savepoint
+	if len(actions) > 0 {
+		sp = txn.Savepoint()
+	}
+
 	// Remove the object from all the indexes
 	for name, indexSchema := range tableSchema.Indexes {
 		indexTxn := txn.writableIndex(table, name)
@@ -366,6 +650,16 @@ func (txn *Txn) Delete(table string, obj interface{}) error {
 			}
 		}
 	}
+	if tableSchema.Versioned {
+		txn.removeVersion(table, idVal)
+	}
+	if tableSchema.TTL != nil {
+		txn.removeExpiry(tableSchema, existing, idVal)
+	}
+	txn.removeUsage(tableSchema, existing)
+	if tableSchema.LRU != nil {
+		txn.forget(table, idVal)
+	}
 	if txn.changes != nil {
 		txn.changes = append(txn.changes, Change{
 			Table:      table,
@@ -374,6 +668,18 @@ func (txn *Txn) Delete(table string, obj interface{}) error {
 			primaryKey: idVal,
 		})
 	}
+	if txn.db.metrics != nil {
+		txn.db.metrics.IncrCounter(MetricDelete, tableLabels(table), 1)
+	}
+	if sp == nil {
+		return nil
+	}
+	if err := txn.applyForeignKeyActions(actions); err != nil {
+		_ = txn.RollbackTo(sp)
+		txn.releaseSavepoint(sp)
+		return err
+	}
+	txn.releaseSavepoint(sp)
 	return nil
 }
 
@@ -385,13 +691,32 @@ func (txn *Txn) DeletePrefix(table string, prefix_index string, prefix string) (
 	if !txn.write {
 		return false, fmt.Errorf("cannot delete in read-only transaction")
 	}
+	var deleted bool
+	err := txn.recordWrite(func(txn *Txn) (err error) {
+		deleted, err = txn.deletePrefix(table, prefix_index, prefix)
+		return err
+	})
+	return deleted, err
+}
 
+// deletePrefix implements DeletePrefix.
+func (txn *Txn) deletePrefix(table string, prefix_index string, prefix string) (bool, error) {
 	if !strings.HasSuffix(prefix_index, "_prefix") {
 		return false, fmt.Errorf("Index name for DeletePrefix must be a prefix index, Got %v ", prefix_index)
 	}
+	if err := txn.checkLocked(table); err != nil {
+		return false, err
+	}
 
 	deletePrefixIndex := strings.TrimSuffix(prefix_index, "_prefix")
 
+	// Rows referenced by foreign keys have to be deleted one at a time so
+	// the foreign keys are enforced.
+	if len(txn.db.references[table]) > 0 {
+		n, err := txn.DeleteAll(table, prefix_index, prefix)
+		return n > 0, err
+	}
+
 	// Get an iterator over all of the keys with the given prefix.
 	entries, err := txn.Get(table, prefix_index, prefix)
 	if err != nil {
@@ -403,11 +728,9 @@ func (txn *Txn) DeletePrefix(table string, prefix_index string, prefix string) (
 		return false, fmt.Errorf("invalid table '%s'", table)
 	}
 
-	foundAny := false
+	deleted := 0
 	for entry := entries.Next(); entry != nil; entry = entries.Next() {
-		if !foundAny {
-			foundAny = true
-		}
+		deleted++
 		// Get the primary ID of the object
 		idSchema := tableSchema.Indexes[id]
 		idIndexer := idSchema.Indexer.(SingleIndexer)
@@ -418,6 +741,16 @@ func (txn *Txn) DeletePrefix(table string, prefix_index string, prefix string) (
 		if !ok {
 			return false, fmt.Errorf("object missing primary index")
 		}
+		if tableSchema.Versioned {
+			txn.removeVersion(table, idVal)
+		}
+		if tableSchema.TTL != nil {
+			txn.removeExpiry(tableSchema, entry, idVal)
+		}
+		txn.removeUsage(tableSchema, entry)
+		if tableSchema.LRU != nil {
+			txn.forget(table, idVal)
+		}
 		if txn.changes != nil {
 			// Record the deletion
 			idTxn := txn.writableIndex(table, id)
@@ -470,7 +803,10 @@ func (txn *Txn) DeletePrefix(table string, prefix_index string, prefix string) (
 		}
 
 	}
-	if foundAny {
+	if deleted > 0 {
+		if txn.db.metrics != nil {
+			txn.db.metrics.IncrCounter(MetricDelete, tableLabels(table), int64(deleted))
+		}
 		indexTxn := txn.writableIndex(table, deletePrefixIndex)
 		ok = indexTxn.DeletePrefix([]byte(prefix))
 		if !ok {
@@ -547,6 +883,7 @@ func (txn *Txn) FirstWatch(table, index string, args ...interface{}) (<-chan str
 		if !ok {
 			return watch, nil, nil
 		}
+		txn.recordUse(table, obj)
 		return watch, obj, nil
 	}
 
@@ -554,6 +891,7 @@ func (txn *Txn) FirstWatch(table, index string, args ...interface{}) (<-chan str
 	iter := indexTxn.Root().Iterator()
 	watch := iter.SeekPrefixWatch(val)
 	_, value, _ := iter.Next()
+	txn.recordUse(table, value)
 	return watch, value, nil
 }
 
@@ -587,6 +925,7 @@ func (txn *Txn) LastWatch(table, index string, args ...interface{}) (<-chan stru
 		if !ok {
 			return watch, nil, nil
 		}
+		txn.recordUse(table, obj)
 		return watch, obj, nil
 	}
 
@@ -594,6 +933,7 @@ func (txn *Txn) LastWatch(table, index string, args ...interface{}) (<-chan stru
 	iter := indexTxn.Root().ReverseIterator()
 	watch := iter.SeekPrefixWatch(val)
 	_, value, _ := iter.Previous()
+	txn.recordUse(table, value)
 	return watch, value, nil
 }
 
@@ -646,8 +986,10 @@ func (txn *Txn) LongestPrefix(table, index string, args ...interface{}) (interfa
 	}
 
 	// Find the longest prefix match with the given index.
+	txn.recordRead(table, indexSchema.Name, nil)
 	indexTxn := txn.readableIndex(table, indexSchema.Name)
 	if _, value, ok := indexTxn.Root().LongestPrefix(val); ok {
+		txn.recordUse(table, value)
 		return value, nil
 	}
 	return nil, nil
@@ -679,6 +1021,7 @@ func (txn *Txn) getIndexValue(table, index string, args ...interface{}) (*IndexS
 
 	// Hot-path for when there are no arguments
 	if len(args) == 0 {
+		txn.recordRead(table, index, nil)
 		return indexSchema, nil, nil
 	}
 
@@ -694,6 +1037,7 @@ func (txn *Txn) getIndexValue(table, index string, args ...interface{}) (*IndexS
 		if err != nil {
 			return indexSchema, nil, fmt.Errorf("index error: %v", err)
 		}
+		txn.recordRead(table, index, val)
 		return indexSchema, val, err
 	}
 
@@ -702,9 +1046,28 @@ func (txn *Txn) getIndexValue(table, index string, args ...interface{}) (*IndexS
 	if err != nil {
 		return indexSchema, nil, fmt.Errorf("index error: %v", err)
 	}
+	txn.recordRead(table, index, val)
 	return indexSchema, val, err
 }
 
+// primaryKey returns the value of the primary index for an object.
+func (txn *Txn) primaryKey(table string, obj interface{}) ([]byte, error) {
+	tableSchema, ok := txn.db.schema.Tables[table]
+	if !ok {
+		return nil, fmt.Errorf("invalid table '%s'", table)
+	}
+
+	idIndexer := tableSchema.Indexes[id].Indexer.(SingleIndexer)
+	ok, idVal, err := idIndexer.FromObject(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build primary index: %v", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("object missing primary index")
+	}
+	return idVal, nil
+}
+
 // ResultIterator is used to iterate over a list of results from a query on a table.
 //
 // When a ResultIterator is created from a write transaction, the results from
@@ -754,6 +1117,7 @@ func (txn *Txn) Get(table, index string, args ...interface{}) (ResultIterator, e
 	iter := &radixIterator{
 		iter:    indexIter,
 		watchCh: watchCh,
+		use:     txn.useRecorder(table),
 	}
 	return iter, nil
 }
@@ -779,6 +1143,7 @@ func (txn *Txn) GetReverse(table, index string, args ...interface{}) (ResultIter
 	iter := &radixReverseIterator{
 		iter:    indexIter,
 		watchCh: watchCh,
+		use:     txn.useRecorder(table),
 	}
 	return iter, nil
 }
@@ -801,6 +1166,7 @@ func (txn *Txn) LowerBound(table, index string, args ...interface{}) (ResultIter
 	if err != nil {
 		return nil, err
 	}
+	txn.recordRead(table, strings.TrimSuffix(index, "_prefix"), nil)
 
 	// Seek the iterator to the appropriate sub-set
 	indexIter.SeekLowerBound(val)
@@ -808,6 +1174,7 @@ func (txn *Txn) LowerBound(table, index string, args ...interface{}) (ResultIter
 	// Create an iterator
 	iter := &radixIterator{
 		iter: indexIter,
+		use:  txn.useRecorder(table),
 	}
 	return iter, nil
 }
@@ -827,6 +1194,7 @@ func (txn *Txn) ReverseLowerBound(table, index string, args ...interface{}) (Res
 	if err != nil {
 		return nil, err
 	}
+	txn.recordRead(table, strings.TrimSuffix(index, "_prefix"), nil)
 
 	// Seek the iterator to the appropriate sub-set
 	indexIter.SeekReverseLowerBound(val)
@@ -834,6 +1202,7 @@ func (txn *Txn) ReverseLowerBound(table, index string, args ...interface{}) (Res
 	// Create an iterator
 	iter := &radixReverseIterator{
 		iter: indexIter,
+		use:  txn.useRecorder(table),
 	}
 	return iter, nil
 }
@@ -969,6 +1338,9 @@ func (txn *Txn) Defer(fn func()) {
 type radixIterator struct {
 	iter    *iradix.Iterator
 	watchCh <-chan struct{}
+
+	// use records the returned rows as used, if the table tracks reads.
+	use func(obj interface{})
 }
 
 func (r *radixIterator) WatchCh() <-chan struct{} {
@@ -980,12 +1352,16 @@ func (r *radixIterator) Next() interface{} {
 	if !ok {
 		return nil
 	}
+	if r.use != nil {
+		r.use(value)
+	}
 	return value
 }
 
 type radixReverseIterator struct {
 	iter    *iradix.ReverseIterator
 	watchCh <-chan struct{}
+	use     func(obj interface{})
 }
 
 func (r *radixReverseIterator) Next() interface{} {
@@ -993,6 +1369,9 @@ func (r *radixReverseIterator) Next() interface{} {
 	if !ok {
 		return nil
 	}
+	if r.use != nil {
+		r.use(value)
+	}
 	return value
 }
 
@@ -2231,3 +2231,72 @@ func assertNilError(t *testing.T, err error) {
 		t.Fatalf("expected nil error, got %v", err)
 	}
 }
+
+// reflectFieldIndex is a StringFieldIndex that looks up its field with
+// reflect.Value.FieldByName on every call, like the indexers did before
+// field lookups were cached.
+type reflectFieldIndex struct {
+	StringFieldIndex
+}
+
+func (r *reflectFieldIndex) FromObject(obj interface{}) (bool, []byte, error) {
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	fv := v.FieldByName(r.Field)
+	if !fv.IsValid() {
+		return false, nil, fmt.Errorf("field '%s' for %#v is invalid", r.Field, obj)
+	}
+	val := fv.String()
+	if val == "" {
+		return false, nil, nil
+	}
+	val += "\x00"
+	return true, []byte(val), nil
+}
+
+func BenchmarkTxn_Insert(b *testing.B) {
+	objs := make([]interface{}, 10000)
+	for i := range objs {
+		id := fmt.Sprintf("%08d", i)
+		objs[i] = &TestObject{ID: id, Foo: id[:6]}
+	}
+	schema := func(indexer func(field string) Indexer) *DBSchema {
+		return &DBSchema{
+			Tables: map[string]*TableSchema{
+				"main": {
+					Name: "main",
+					Indexes: map[string]*IndexSchema{
+						"id":  {Name: "id", Unique: true, Indexer: indexer("ID")},
+						"foo": {Name: "foo", Indexer: indexer("Foo")},
+					},
+				},
+			},
+		}
+	}
+
+	b.Run("reflect", func(b *testing.B) {
+		benchmarkTxnInsert(b, schema(func(field string) Indexer {
+			return &reflectFieldIndex{StringFieldIndex{Field: field}}
+		}), objs)
+	})
+	b.Run("cached", func(b *testing.B) {
+		benchmarkTxnInsert(b, schema(func(field string) Indexer {
+			return &StringFieldIndex{Field: field}
+		}), objs)
+	})
+}
+
+func benchmarkTxnInsert(b *testing.B, schema *DBSchema, objs []interface{}) {
+	for i := 0; i < b.N; i++ {
+		db, err := NewMemDB(schema)
+		if err != nil {
+			b.Fatalf("err: %v", err)
+		}
+		txn := db.Txn(true)
+		for _, obj := range objs {
+			if err := txn.Insert("main", obj); err != nil {
+				b.Fatalf("err: %v", err)
+			}
+		}
+		txn.Commit()
+	}
+}
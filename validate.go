@@ -0,0 +1,71 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import "fmt"
+
+// ValidatorFunc checks an object that is about to be inserted into a table
+// and returns an error describing why it is invalid. The transaction doing
+// the insert is passed in so validators can read other tables; it reflects
+// every write made earlier in the transaction.
+type ValidatorFunc func(txn *Txn, obj interface{}) error
+
+// Validator is a named rule run by Txn.Insert before an object is added to
+// a table.
+type Validator struct {
+	// Name identifies the rule in a ValidationError. It must be unique among
+	// the validators of a table.
+	Name string
+
+	Func ValidatorFunc
+}
+
+// Validate is used to validate the validator.
+func (v *Validator) Validate() error {
+	if v.Name == "" {
+		return fmt.Errorf("missing validator name")
+	}
+	if v.Func == nil {
+		return fmt.Errorf("missing validator function for '%s'", v.Name)
+	}
+	return nil
+}
+
+// ValidationError is returned by Txn.Insert when an object is rejected by
+// one of the validators of a table.
+type ValidationError struct {
+	// Table is the table the object was inserted into and Rule is the name
+	// of the validator that rejected it.
+	Table string
+	Rule  string
+
+	// Err is the error returned by the validator.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("validation %q failed for table %q: %v", e.Rule, e.Table, e.Err)
+}
+
+// Unwrap returns the error returned by the validator.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validate runs the validators of a table against an object.
+func (txn *Txn) validate(tableSchema *TableSchema, obj interface{}) error {
+	if txn.replica || txn.applying {
+		return nil
+	}
+	for _, validator := range tableSchema.Validators {
+		if err := validator.Func(txn, obj); err != nil {
+			return &ValidationError{
+				Table: tableSchema.Name,
+				Rule:  validator.Name,
+				Err:   err,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var errOutOfRange = errors.New("out of range")
+
+func testValidatorSchema() *DBSchema {
+	schema := testValidSchema()
+	schema.Tables["allowed"] = &TableSchema{
+		Name: "allowed",
+		Indexes: map[string]*IndexSchema{
+			"id": &IndexSchema{
+				Name:    "id",
+				Unique:  true,
+				Indexer: &StringFieldIndex{Field: "ID"},
+			},
+		},
+	}
+	schema.Tables["main"].Validators = []*Validator{
+		{
+			Name: "bar-range",
+			Func: func(_ *Txn, obj interface{}) error {
+				if bar := obj.(*TestObject).Bar; bar < 1 || bar > 65535 {
+					return errOutOfRange
+				}
+				return nil
+			},
+		},
+		{
+			Name: "foo-allowed",
+			Func: func(txn *Txn, obj interface{}) error {
+				raw, err := txn.First("allowed", "id", obj.(*TestObject).Foo)
+				if err != nil {
+					return err
+				}
+				if raw == nil {
+					return fmt.Errorf("foo is not allowed")
+				}
+				return nil
+			},
+		},
+	}
+	return schema
+}
+
+func TestValidator_Validate(t *testing.T) {
+	s := testValidatorSchema()
+	noErr(t, s.Validate())
+
+	s.Tables["main"].Validators = append(s.Tables["main"].Validators, &Validator{Name: "bar-range", Func: func(*Txn, interface{}) error { return nil }})
+	if err := s.Validate(); err == nil {
+		t.Fatalf("should not validate, duplicate name")
+	}
+
+	s.Tables["main"].Validators = []*Validator{{Name: "nope"}}
+	if err := s.Validate(); err == nil {
+		t.Fatalf("should not validate, missing func")
+	}
+
+	s.Tables["main"].Validators = []*Validator{{Func: func(*Txn, interface{}) error { return nil }}}
+	if err := s.Validate(); err == nil {
+		t.Fatalf("should not validate, missing name")
+	}
+}
+
+func TestTxn_Insert_Validators(t *testing.T) {
+	db, err := NewMemDB(testValidatorSchema())
+	noErr(t, err)
+
+	txn := db.Txn(true)
+	defer txn.Abort()
+	txn.TrackChanges()
+
+	// Rejected objects don't touch any index
+	obj := &TestObject{ID: "a", Foo: "xyz", Bar: 70000, Qux: []string{"q"}}
+	err = txn.Insert("main", obj)
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) || vErr.Table != "main" || vErr.Rule != "bar-range" {
+		t.Fatalf("bad: %v", err)
+	}
+	if !errors.Is(err, errOutOfRange) {
+		t.Fatalf("should unwrap to the validator error: %v", err)
+	}
+	if raw, _ := txn.First("main", "id", "a"); raw != nil {
+		t.Fatalf("should not exist: %#v", raw)
+	}
+	if changes := txn.Changes(); len(changes) != 0 {
+		t.Fatalf("bad: %#v", changes)
+	}
+
+	// Validators read other tables through the same transaction
+	obj.Bar = 80
+	err = txn.Insert("main", obj)
+	if !errors.As(err, &vErr) || vErr.Rule != "foo-allowed" {
+		t.Fatalf("bad: %v", err)
+	}
+
+	noErr(t, txn.Insert("allowed", &TestObject{ID: "xyz"}))
+	noErr(t, txn.Insert("main", obj))
+	if raw, _ := txn.First("main", "id", "a"); raw != obj {
+		t.Fatalf("bad: %#v", raw)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// VerifyReport is the result of MemDB.Verify.
+type VerifyReport struct {
+	// Rows is the number of rows verified.
+	Rows int
+
+	// Problems lists the inconsistencies found, ordered by table, index and
+	// key.
+	Problems []IndexProblem
+}
+
+// IndexProblem is an inconsistency between an index and the objects of its
+// table.
+type IndexProblem struct {
+	Table string
+	Index string
+
+	// Key is the index key, and Object the row the problem is about: the
+	// row that should have the key, or the one it leads to if it shouldn't
+	// be in the index.
+	Key    []byte
+	Object interface{}
+
+	// Reason describes the problem: the key is missing, unexpected, leads
+	// to another row or is shared by several rows of a unique index, or the
+	// indexer failed or returned no value for an index not allowing missing
+	// values.
+	Reason string
+}
+
+func (p IndexProblem) String() string {
+	return fmt.Sprintf("index %q of table %q, key %q: %s", p.Index, p.Table, p.Key, p.Reason)
+}
+
+// Err returns an error describing the problems found, or nil if there are
+// none.
+func (r *VerifyReport) Err() error {
+	switch len(r.Problems) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("inconsistent index: %s", r.Problems[0])
+	default:
+		return fmt.Errorf("inconsistent indexes: %s, and %d more problems", r.Problems[0], len(r.Problems)-1)
+	}
+}
+
+// Verify checks that the indexes of every table are consistent with their
+// objects. Indexers only run on writes, so an object modified in place after
+// it was inserted, or an indexer that doesn't always return the same values
+// for the same object, leaves keys out of date. Verify runs the indexers
+// again against the objects of the id index, and reports the keys that are
+// missing, or that are present but shouldn't be.
+//
+// It runs against a read transaction, so it doesn't block writers, but it
+// takes time proportional to the size of the database, and objects must not
+// be modified concurrently.
+func (db *MemDB) Verify() *VerifyReport {
+	txn := db.Txn(false)
+	report := new(VerifyReport)
+	tables := make([]string, 0, len(db.schema.Tables))
+	for table := range db.schema.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		txn.verifyTable(db.schema.Tables[table], report)
+	}
+	return report
+}
+
+// sharedKey replaces the row expected for a key of a unique index when
+// several rows should have it.
+type sharedKey struct{}
+
+// verifyTable adds the problems of the indexes of a table to the report.
+func (txn *Txn) verifyTable(tableSchema *TableSchema, report *VerifyReport) {
+	table := tableSchema.Name
+	names := make([]string, 0, len(tableSchema.Indexes))
+	for name := range tableSchema.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make(map[string][]IndexProblem, len(names))
+	addProblem := func(index string, key []byte, obj interface{}, reason string) {
+		problems[index] = append(problems[index], IndexProblem{
+			Table:  table,
+			Index:  index,
+			Key:    key,
+			Object: obj,
+			Reason: reason,
+		})
+	}
+
+	// Compute the keys each index should have
+	expected := make(map[string]map[string]interface{}, len(names))
+	for _, name := range names {
+		expected[name] = make(map[string]interface{})
+	}
+	txn.readableIndex(table, id).Root().Walk(func(k []byte, obj interface{}) bool {
+		report.Rows++
+		idVal, err := txn.primaryKey(table, obj)
+		if err != nil {
+			addProblem(id, k, obj, err.Error())
+			return false
+		}
+		for _, name := range names {
+			indexSchema := tableSchema.Indexes[name]
+			ok, vals, err := indexValues(indexSchema.Indexer, obj)
+			if err != nil {
+				addProblem(name, nil, obj, fmt.Sprintf("failed to build index: %v", err))
+				continue
+			}
+			if !ok {
+				if !indexSchema.AllowMissing {
+					addProblem(name, nil, obj, "missing value")
+				}
+				continue
+			}
+			for _, val := range vals {
+				key := val
+				if !indexSchema.Unique {
+					key = append(append(make([]byte, 0, len(val)+len(idVal)), val...), idVal...)
+				}
+				if other, ok := expected[name][string(key)]; ok && !reflect.DeepEqual(other, obj) {
+					addProblem(name, key, obj, "value shared by several rows")
+					expected[name][string(key)] = sharedKey{}
+					continue
+				}
+				expected[name][string(key)] = obj
+			}
+		}
+		return false
+	})
+
+	// Compare them with the keys of each index
+	for _, name := range names {
+		want := expected[name]
+		txn.readableIndex(table, name).Root().Walk(func(k []byte, obj interface{}) bool {
+			expectedObj, ok := want[string(k)]
+			switch {
+			case expectedObj == sharedKey{}:
+			case !ok:
+				addProblem(name, k, obj, "unexpected key")
+			case !reflect.DeepEqual(expectedObj, obj):
+				addProblem(name, k, expectedObj, "key leads to another row")
+			}
+			delete(want, string(k))
+			return false
+		})
+		for key, obj := range want {
+			if obj != (sharedKey{}) {
+				addProblem(name, []byte(key), obj, "missing key")
+			}
+		}
+
+		sort.SliceStable(problems[name], func(i, j int) bool {
+			return string(problems[name][i].Key) < string(problems[name][j].Key)
+		})
+		report.Problems = append(report.Problems, problems[name]...)
+	}
+}
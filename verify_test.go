@@ -0,0 +1,105 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func testVerifyProblems(report *VerifyReport) string {
+	problems := make([]string, len(report.Problems))
+	for i, problem := range report.Problems {
+		problems[i] = problem.Index + " " + strings.ReplaceAll(string(problem.Key), "\x00", ".") + ": " + problem.Reason
+	}
+	return strings.Join(problems, "\n")
+}
+
+func TestVerify(t *testing.T) {
+	db := testDB(t)
+	a, b := testSavepointObj("a", "x"), testSavepointObj("b", "x")
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", a))
+	noErr(t, txn.Insert("main", b))
+	txn.Commit()
+
+	report := db.Verify()
+	if report.Rows != 2 || len(report.Problems) != 0 {
+		t.Fatalf("bad report: %#v", report)
+	}
+	noErr(t, report.Err())
+
+	// Modify the objects in place
+	a.Foo = "y"
+	b.ID = "c"
+	report = db.Verify()
+	expected := strings.Join([]string{
+		"foo x.a.: unexpected key",
+		"foo x.b.: unexpected key",
+		"foo x.c.: missing key",
+		"foo y.a.: missing key",
+		"id b.: unexpected key",
+		"id c.: missing key",
+		"qux x.b.: unexpected key",
+		"qux x.c.: missing key",
+	}, "\n")
+	if problems := testVerifyProblems(report); problems != expected {
+		t.Fatalf("bad problems:\n%s", problems)
+	}
+	if report.Problems[0].Object != a || report.Problems[1].Object != b {
+		t.Fatalf("bad objects: %#v", report.Problems)
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "and 7 more problems") {
+		t.Fatalf("bad error: %v", err)
+	}
+
+	// Inserting the objects again adds the missing keys, but the old keys
+	// are computed from the modified objects so they aren't deleted
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", a))
+	noErr(t, txn.Insert("main", b))
+	txn.Commit()
+	expected = strings.Join([]string{
+		"foo x.a.: unexpected key",
+		"foo x.b.: unexpected key",
+		"id b.: unexpected key",
+		"qux x.b.: unexpected key",
+	}, "\n")
+	if problems := testVerifyProblems(db.Verify()); problems != expected {
+		t.Fatalf("bad problems:\n%s", problems)
+	}
+}
+
+func TestVerify_Unique(t *testing.T) {
+	schema := testValidSchema()
+	schema.Tables["main"].Indexes["foo"].Unique = true
+	db, err := NewMemDB(schema)
+	noErr(t, err)
+
+	a := testSavepointObj("a", "x")
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", a))
+	noErr(t, txn.Insert("main", testSavepointObj("b", "y")))
+	txn.Commit()
+
+	// Both rows now have the value y, which leads to b
+	a.Foo = "y"
+	expected := strings.Join([]string{
+		"foo x.: unexpected key",
+		"foo y.: value shared by several rows",
+	}, "\n")
+	if problems := testVerifyProblems(db.Verify()); problems != expected {
+		t.Fatalf("bad problems:\n%s", problems)
+	}
+
+	// A missing value is reported for indexes not allowing it
+	a.Foo = ""
+	expected = strings.Join([]string{
+		"foo : missing value",
+		"foo x.: unexpected key",
+	}, "\n")
+	if problems := testVerifyProblems(db.Verify()); problems != expected {
+		t.Fatalf("bad problems:\n%s", problems)
+	}
+}
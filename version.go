@@ -0,0 +1,237 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	iradix "github.com/hashicorp/go-immutable-radix"
+)
+
+const (
+	// versionIndex is the name of the internal index of each table mapping
+	// primary keys to the version of their row.
+	versionIndex = "\x00version"
+
+	// tableIndexName is the name under which the commit index of each table
+	// is stored in the root.
+	tableIndexName = "\x00index"
+)
+
+var (
+	// commitIndexPath is the path from the root to the commit index of the
+	// database.
+	commitIndexPath = []byte("\x00index")
+)
+
+// RowVersion is the versioning metadata maintained for every row of the
+// tables with TableSchema.Versioned set. Both indexes are commit indexes as
+// returned by Txn.CommitIndex.
+//
+// Rows written by a transaction that hasn't committed yet report a zero
+// index for the parts of the version that transaction changed.
+type RowVersion struct {
+	// CreateIndex is the commit index of the transaction that inserted the
+	// row.
+	CreateIndex uint64
+
+	// ModifyIndex is the commit index of the transaction that last inserted
+	// or updated the row.
+	ModifyIndex uint64
+}
+
+// VersionConflictError is returned by InsertCAS and DeleteCAS when the row
+// doesn't have the expected version.
+type VersionConflictError struct {
+	Table string
+
+	// Expected is the ModifyIndex given by the caller and Actual is the
+	// ModifyIndex of the stored row, which is zero if the row doesn't exist
+	// or was written earlier in the same transaction.
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict in table %q: expected %d, found %d", e.Table, e.Expected, e.Actual)
+}
+
+// commitStamp is shared by all the rows written by a transaction. Its index
+// is set when the transaction commits, so the rows don't have to be
+// rewritten once the commit index is known.
+type commitStamp struct {
+	index atomic.Uint64
+}
+
+// rowVersion is the value stored in the version index.
+type rowVersion struct {
+	create *commitStamp
+	modify *commitStamp
+}
+
+func (v *rowVersion) version() RowVersion {
+	return RowVersion{
+		CreateIndex: v.create.index.Load(),
+		ModifyIndex: v.modify.index.Load(),
+	}
+}
+
+// CommitIndex returns the commit index of the database as seen by this
+// transaction. The commit index starts at zero and is incremented by every
+// committed write transaction that modifies the database.
+func (txn *Txn) CommitIndex() uint64 {
+	raw, ok := txn.rootTxn.Get(commitIndexPath)
+	if !ok {
+		return 0
+	}
+	return raw.(uint64)
+}
+
+// Version returns the version of the stored row with the same primary key
+// as obj, and whether such a row exists. An error is returned if the table
+// isn't versioned.
+func (txn *Txn) Version(table string, obj interface{}) (RowVersion, bool, error) {
+	idVal, err := txn.primaryKey(table, obj)
+	if err != nil {
+		return RowVersion{}, false, err
+	}
+	return txn.version(table, idVal)
+}
+
+// FirstWithVersion is used to return the first matching object for the
+// given constraints on the index, along with its version.
+func (txn *Txn) FirstWithVersion(table, index string, args ...interface{}) (interface{}, RowVersion, error) {
+	obj, err := txn.First(table, index, args...)
+	if err != nil || obj == nil {
+		return obj, RowVersion{}, err
+	}
+	version, _, err := txn.Version(table, obj)
+	return obj, version, err
+}
+
+// InsertCAS is used to insert or update an object only if the stored row
+// is at the expected version. An expected version of zero requires that no
+// row with the same primary key exists. A *VersionConflictError is returned
+// if the version doesn't match.
+func (txn *Txn) InsertCAS(table string, obj interface{}, expected uint64) error {
+	if !txn.write {
+		return fmt.Errorf("cannot insert in read-only transaction")
+	}
+	if err := txn.checkVersion(table, obj, expected, false); err != nil {
+		return err
+	}
+	return txn.Insert(table, obj)
+}
+
+// DeleteCAS is used to delete an object only if the stored row is at the
+// expected version. ErrNotFound is returned if there is no such row, and a
+// *VersionConflictError if the version doesn't match.
+func (txn *Txn) DeleteCAS(table string, obj interface{}, expected uint64) error {
+	if !txn.write {
+		return fmt.Errorf("cannot delete in read-only transaction")
+	}
+	if err := txn.checkVersion(table, obj, expected, true); err != nil {
+		return err
+	}
+	return txn.Delete(table, obj)
+}
+
+// checkVersion compares the version of the stored row with the same primary
+// key as obj against the expected version.
+func (txn *Txn) checkVersion(table string, obj interface{}, expected uint64, mustExist bool) error {
+	version, exists, err := txn.Version(table, obj)
+	if err != nil {
+		return err
+	}
+	if !exists && mustExist {
+		return ErrNotFound
+	}
+
+	// A row written earlier in this transaction has a zero ModifyIndex, so
+	// it only matches when the caller expects no row at all.
+	if (expected == 0 && exists) || (expected != 0 && version.ModifyIndex != expected) {
+		return &VersionConflictError{
+			Table:    table,
+			Expected: expected,
+			Actual:   version.ModifyIndex,
+		}
+	}
+	return nil
+}
+
+// version looks up the version of a row by its primary key.
+func (txn *Txn) version(table string, idVal []byte) (RowVersion, bool, error) {
+	if !txn.db.schema.Tables[table].Versioned {
+		return RowVersion{}, false, fmt.Errorf("table '%s' isn't versioned", table)
+	}
+	raw, ok := txn.indexGet(table, versionIndex, idVal)
+	if !ok {
+		return RowVersion{}, false, nil
+	}
+	return raw.(*rowVersion).version(), true, nil
+}
+
+// stampVersion records that the row with the given primary key was written
+// by this transaction.
+func (txn *Txn) stampVersion(table string, idVal []byte) {
+	if txn.stamp == nil {
+		txn.stamp = new(commitStamp)
+	}
+
+	versionTxn := txn.writableIndex(table, versionIndex)
+	version := &rowVersion{create: txn.stamp, modify: txn.stamp}
+	if raw, ok := versionTxn.Get(idVal); ok {
+		version.create = raw.(*rowVersion).create
+	}
+	versionTxn.Insert(idVal, version)
+}
+
+// removeVersion removes the version of a deleted row.
+func (txn *Txn) removeVersion(table string, idVal []byte) {
+	txn.writableIndex(table, versionIndex).Delete(idVal)
+}
+
+// TableIndex returns the commit index of the last transaction that modified
+// the given table, as seen by this transaction. Zero is returned if the
+// table was never modified or doesn't exist.
+func (txn *Txn) TableIndex(table string) uint64 {
+	_, index := txn.TableIndexWatch(table)
+	return index
+}
+
+// TableIndexWatch is like TableIndex but also returns a channel that is
+// closed when a transaction modifying the table commits. The channel is nil
+// if the table doesn't exist.
+func (txn *Txn) TableIndexWatch(table string) (<-chan struct{}, uint64) {
+	if _, ok := txn.db.schema.Tables[table]; !ok {
+		return nil, 0
+	}
+	watch, raw, ok := txn.rootTxn.Root().GetWatch(indexPath(table, tableIndexName))
+	if !ok {
+		return watch, 0
+	}
+	return watch, raw.(uint64)
+}
+
+// advanceCommitIndex increments the commit index of the database within the
+// root transaction, sets it as the index of every modified table and stamps
+// the rows written by the transaction with it.
+func (txn *Txn) advanceCommitIndex(rootTxn *iradix.Txn, tables map[string]struct{}) uint64 {
+	var index uint64
+	if raw, ok := rootTxn.Get(commitIndexPath); ok {
+		index = raw.(uint64)
+	}
+	index++
+	rootTxn.Insert(commitIndexPath, index)
+
+	for table := range tables {
+		rootTxn.Insert(indexPath(table, tableIndexName), index)
+	}
+
+	if txn.stamp != nil {
+		txn.stamp.index.Store(index)
+	}
+	return index
+}
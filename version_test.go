@@ -0,0 +1,242 @@
+// Copyright IBM Corp. 2015, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package memdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testVersionedDB(t *testing.T) *MemDB {
+	t.Helper()
+	schema := testValidSchema()
+	schema.Tables["main"].Versioned = true
+	db, err := NewMemDB(schema)
+	noErr(t, err)
+	return db
+}
+
+func TestTxn_CommitIndex(t *testing.T) {
+	db := testDB(t)
+	if idx := db.Txn(false).CommitIndex(); idx != 0 {
+		t.Fatalf("bad: %d", idx)
+	}
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	txn.Commit()
+	if idx := db.Txn(false).CommitIndex(); idx != 1 {
+		t.Fatalf("bad: %d", idx)
+	}
+
+	// Transactions that don't modify anything leave the index alone
+	txn = db.Txn(true)
+	txn.Commit()
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("c", "x")))
+	txn.Abort()
+	if idx := db.Txn(false).CommitIndex(); idx != 1 {
+		t.Fatalf("bad: %d", idx)
+	}
+}
+
+func TestTxn_Version(t *testing.T) {
+	db := testVersionedDB(t)
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+
+	// Pending writes don't have a commit index yet
+	version, ok, err := txn.Version("main", testSavepointObj("a", "x"))
+	noErr(t, err)
+	if !ok || version != (RowVersion{}) {
+		t.Fatalf("bad: %v %#v", ok, version)
+	}
+	txn.Commit()
+
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("b", "x")))
+	txn.Commit()
+
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "y")))
+	txn.Commit()
+
+	read := db.Txn(false)
+	obj, version, err := read.FirstWithVersion("main", "id", "a")
+	noErr(t, err)
+	if obj.(*TestObject).Foo != "y" || version != (RowVersion{CreateIndex: 1, ModifyIndex: 3}) {
+		t.Fatalf("bad: %#v %#v", obj, version)
+	}
+	_, version, err = read.FirstWithVersion("main", "id", "b")
+	noErr(t, err)
+	if version != (RowVersion{CreateIndex: 2, ModifyIndex: 2}) {
+		t.Fatalf("bad: %#v", version)
+	}
+	if _, ok, _ := read.Version("main", testSavepointObj("nope", "x")); ok {
+		t.Fatalf("should not exist")
+	}
+
+	// Deleting and re-inserting starts a new version
+	txn = db.Txn(true)
+	noErr(t, txn.Delete("main", testSavepointObj("a", "y")))
+	txn.Commit()
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "z")))
+	txn.Commit()
+	version, _, err = db.Txn(false).Version("main", testSavepointObj("a", "z"))
+	noErr(t, err)
+	if version != (RowVersion{CreateIndex: 5, ModifyIndex: 5}) {
+		t.Fatalf("bad: %#v", version)
+	}
+}
+
+func TestTxn_InsertCAS(t *testing.T) {
+	db := testVersionedDB(t)
+
+	txn := db.Txn(true)
+	noErr(t, txn.InsertCAS("main", testSavepointObj("a", "x"), 0))
+
+	// A row written in the same transaction has no version to match
+	err := txn.InsertCAS("main", testSavepointObj("a", "y"), 1)
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Expected != 1 || conflict.Actual != 0 {
+		t.Fatalf("bad: %v", err)
+	}
+	txn.Commit()
+
+	txn = db.Txn(true)
+	defer txn.Abort()
+	err = txn.InsertCAS("main", testSavepointObj("a", "y"), 0)
+	if !errors.As(err, &conflict) || conflict.Table != "main" || conflict.Actual != 1 {
+		t.Fatalf("bad: %v", err)
+	}
+	err = txn.InsertCAS("main", testSavepointObj("a", "y"), 2)
+	if !errors.As(err, &conflict) {
+		t.Fatalf("bad: %v", err)
+	}
+	noErr(t, txn.InsertCAS("main", testSavepointObj("a", "y"), 1))
+
+	raw, err := txn.First("main", "id", "a")
+	noErr(t, err)
+	if raw.(*TestObject).Foo != "y" {
+		t.Fatalf("bad: %#v", raw)
+	}
+}
+
+func TestTxn_DeleteCAS(t *testing.T) {
+	db := testVersionedDB(t)
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	txn.Commit()
+
+	txn = db.Txn(true)
+	defer txn.Abort()
+	if err := txn.DeleteCAS("main", testSavepointObj("b", "x"), 1); err != ErrNotFound {
+		t.Fatalf("bad: %v", err)
+	}
+	err := txn.DeleteCAS("main", testSavepointObj("a", "x"), 7)
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Actual != 1 {
+		t.Fatalf("bad: %v", err)
+	}
+	noErr(t, txn.DeleteCAS("main", testSavepointObj("a", "x"), 1))
+	if _, ok, _ := txn.Version("main", testSavepointObj("a", "x")); ok {
+		t.Fatalf("version should be removed")
+	}
+
+	read := db.Txn(false)
+	if err := read.DeleteCAS("main", testSavepointObj("a", "x"), 1); err == nil {
+		t.Fatalf("expected error in read-only transaction")
+	}
+}
+
+func TestTxn_Version_Savepoint(t *testing.T) {
+	db := testVersionedDB(t)
+
+	txn := db.Txn(true)
+	sp := txn.Savepoint()
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	noErr(t, txn.RollbackTo(sp))
+	if _, ok, _ := txn.Version("main", testSavepointObj("a", "x")); ok {
+		t.Fatalf("version should be rolled back")
+	}
+	txn.Commit()
+
+	if idx := db.Txn(false).CommitIndex(); idx != 0 {
+		t.Fatalf("bad: %d", idx)
+	}
+}
+
+func TestTxn_Version_Unversioned(t *testing.T) {
+	db := testDB(t)
+
+	// Unversioned tables don't have a version index
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	if _, ok := txn.modified[tableIndex{"main", versionIndex}]; ok {
+		t.Fatalf("version should not be written")
+	}
+	txn.Commit()
+
+	read := db.Txn(false)
+	if _, ok := read.rootTxn.Get(indexPath("main", versionIndex)); ok {
+		t.Fatalf("version index should not exist")
+	}
+	if _, _, err := read.Version("main", testSavepointObj("a", "x")); err == nil {
+		t.Fatalf("expected error")
+	}
+	txn = db.Txn(true)
+	defer txn.Abort()
+	if err := txn.InsertCAS("main", testSavepointObj("b", "x"), 0); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestTxn_TableIndex(t *testing.T) {
+	db, err := NewMemDB(testPreCommitSchema())
+	noErr(t, err)
+
+	read := db.Txn(false)
+	mainWatch, index := read.TableIndexWatch("main")
+	if index != 0 {
+		t.Fatalf("bad: %d", index)
+	}
+	otherWatch, _ := read.TableIndexWatch("other")
+	if watch, _ := read.TableIndexWatch("nope"); watch != nil {
+		t.Fatalf("unknown table should not have a watch")
+	}
+
+	txn := db.Txn(true)
+	noErr(t, txn.Insert("main", testSavepointObj("a", "x")))
+	txn.Commit()
+
+	select {
+	case <-mainWatch:
+	case <-time.After(time.Second):
+		t.Fatalf("main watch should fire")
+	}
+	select {
+	case <-otherWatch:
+		t.Fatalf("other watch should not fire")
+	default:
+	}
+
+	txn = db.Txn(true)
+	noErr(t, txn.Insert("other", &TestObject{ID: "b"}))
+	txn.Commit()
+
+	read = db.Txn(false)
+	if idx := read.TableIndex("main"); idx != 1 {
+		t.Fatalf("bad: %d", idx)
+	}
+	if idx := read.TableIndex("other"); idx != 2 {
+		t.Fatalf("bad: %d", idx)
+	}
+	if idx := read.CommitIndex(); idx != 2 {
+		t.Fatalf("bad: %d", idx)
+	}
+}
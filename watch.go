@@ -79,6 +79,28 @@ func (w WatchSet) WatchCtx(ctx context.Context) error {
 		return nil
 	}
 
+	if sink := watchMetrics.Load(); sink != nil {
+		return w.watchWithMetrics(ctx, *sink)
+	}
+	return w.watch(ctx)
+}
+
+// watchWithMetrics is WatchCtx reporting its metrics to a sink.
+func (w WatchSet) watchWithMetrics(ctx context.Context, sink MetricsSink) error {
+	sink.AddSample(MetricWatchChannels, nil, float64(len(w)))
+	start := time.Now()
+	err := w.watch(ctx)
+	sink.AddSample(MetricWatchWait, nil, milliseconds(time.Since(start)))
+	if err != nil {
+		sink.IncrCounter(MetricWatchCancelled, nil, 1)
+	} else {
+		sink.IncrCounter(MetricWatchFired, nil, 1)
+	}
+	return err
+}
+
+// watch implements WatchCtx.
+func (w WatchSet) watch(ctx context.Context) error {
 	if n := len(w); n <= aFew {
 		idx := 0
 		chunk := make([]<-chan struct{}, aFew)